@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+)
+
+// Returns the children, keyed by DN, to be created (those only in children)
+// and deleted (those only in old), sorted by DN, so that a parent can be
+// changed by adding and removing its children one by one.
+func diffChildren(old, children map[string]*ucsclient.ManagedObject) []*ucsclient.ManagedObject {
+	dns := make([]string, 0, len(old)+len(children))
+	for dn := range children {
+		if _, ok := old[dn]; !ok {
+			dns = append(dns, dn)
+		}
+	}
+
+	for dn := range old {
+		if _, ok := children[dn]; !ok {
+			dns = append(dns, dn)
+		}
+	}
+	sort.Strings(dns)
+
+	changes := make([]*ucsclient.ManagedObject, 0, len(dns))
+	for _, dn := range dns {
+		if mo, ok := children[dn]; ok {
+			changes = append(changes, mo.SetStatus(ucsclient.StatusCreated))
+		} else {
+			changes = append(changes, old[dn].SetStatus(ucsclient.StatusDeleted))
+		}
+	}
+	return changes
+}
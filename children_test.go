@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+)
+
+func TestDiffChildren(t *testing.T) {
+	poolDn := "org-root/mac-pool-esx"
+	old := map[string]*ucsclient.ManagedObject{
+		poolDn + "/block-a": ucsclient.NewManagedObject("macpoolBlock", poolDn+"/block-a"),
+		poolDn + "/block-b": ucsclient.NewManagedObject("macpoolBlock", poolDn+"/block-b"),
+	}
+	children := map[string]*ucsclient.ManagedObject{
+		poolDn + "/block-b": ucsclient.NewManagedObject("macpoolBlock", poolDn+"/block-b"),
+		poolDn + "/block-c": ucsclient.NewManagedObject("macpoolBlock", poolDn+"/block-c"),
+	}
+
+	changes := diffChildren(old, children)
+
+	expected := []struct {
+		dn     string
+		status string
+	}{
+		{poolDn + "/block-a", ucsclient.StatusDeleted},
+		{poolDn + "/block-c", ucsclient.StatusCreated},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("%d changes expected; got %d", len(expected), len(changes))
+	}
+
+	for i, change := range changes {
+		if change.Dn() != expected[i].dn || change.Get("status") != expected[i].status {
+			t.Errorf("%s %s expected; got %s %s", expected[i].status, expected[i].dn, change.Get("status"), change.Dn())
+		}
+	}
+
+	if changes := diffChildren(nil, nil); len(changes) != 0 {
+		t.Errorf("no changes expected; got %d", len(changes))
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/CiscoUcs/UCS-Terraform/ipman"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Reports how the IPs allocated to the vNICs called `pool` drift from the
+// service profiles which actually exist in UCS. It never changes the
+// inventory; see ipman.Repair for that.
+func dataSourceUcsIPReconciliation() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceUcsIPReconciliationRead,
+		Schema: map[string]*schema.Schema{
+			"pool": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Name of the vNICs whose allocations are reconciled",
+			},
+			"clean": &schema.Schema{
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"orphaned":   reconciledAllocationsSchema("Allocated to vNICs of service profiles which do not exist anymore"),
+			"unowned":    reconciledAllocationsSchema("Recorded without an owner by older versions"),
+			"duplicated": reconciledAllocationsSchema("Recorded more than once"),
+			"missing":    reconciledAllocationsSchema("vNICs of service profiles which do not hold an IP"),
+		},
+	}
+}
+
+func reconciledAllocationsSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: description,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"ip": &schema.Schema{
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"owner": &schema.Schema{
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceUcsIPReconciliationRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	allocator := meta.(*providerMeta).allocator
+	client.Logger.Debug("Entering dataSourceUcsIPReconciliationRead(...)\n")
+
+	name := d.Get("pool").(string)
+	owners, err := client.ServiceProfileVNICs(name)
+	if err != nil {
+		return err
+	}
+
+	live := make([]ipman.Allocation, 0, len(owners))
+	for _, owner := range owners {
+		live = append(live, ipman.Allocation{Owner: owner})
+	}
+
+	pool, err := vnicPool(name, "")
+	if err != nil {
+		return err
+	}
+
+	// The inventory is shared with the ucs_ip_allocation resources of the
+	// ip_pool of the same name, if any, which are not vNICs.
+	report, err := ipman.Reconcile(allocator, pool, live, isVnicDn)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(name)
+	d.Set("clean", report.Clean())
+	d.Set("orphaned", flattenAllocations(report.Orphaned))
+	d.Set("unowned", flattenAllocations(report.Unowned))
+	d.Set("duplicated", flattenAllocations(report.Duplicated))
+	d.Set("missing", flattenAllocations(report.Missing))
+
+	client.Logger.Debug("Exiting dataSourceUcsIPReconciliationRead(...)\n")
+	return nil
+}
+
+// Determines whether owner is the DN of the vNIC of a service profile, e.g.
+// org-root/org-a/ls-foo/ether-eth0.
+func isVnicDn(owner string) bool {
+	parts := strings.Split(owner, "/")
+	if len(parts) < 3 || parts[0] != "org-root" {
+		return false
+	}
+
+	profile, vnic := parts[len(parts)-2], parts[len(parts)-1]
+	return strings.HasPrefix(profile, "ls-") && profile != "ls-" &&
+		strings.HasPrefix(vnic, "ether-") && vnic != "ether-"
+}
+
+func flattenAllocations(allocations []ipman.Allocation) []map[string]interface{} {
+	ret := make([]map[string]interface{}, 0, len(allocations))
+	for _, a := range allocations {
+		ret = append(ret, map[string]interface{}{
+			"ip":    ipOrEmpty(a.IP),
+			"owner": a.Owner,
+		})
+	}
+	return ret
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/CiscoUcs/UCS-Terraform/ipman"
+)
+
+func TestFlattenAllocations(t *testing.T) {
+	flattened := flattenAllocations([]ipman.Allocation{
+		{IP: net.ParseIP("10.0.1.2"), Owner: "org-root/ls-foo/ether-eth0"},
+		{Owner: "org-root/ls-bar/ether-eth0"},
+	})
+
+	if len(flattened) != 2 {
+		t.Fatalf("2 allocations expected; got %d", len(flattened))
+	}
+
+	if ip := flattened[0]["ip"]; ip != "10.0.1.2" {
+		t.Errorf("%s expected; got %s", "10.0.1.2", ip)
+	}
+
+	if ip := flattened[1]["ip"]; ip != "" {
+		t.Errorf("blank IP expected; got %s", ip)
+	}
+
+	if owner := flattened[1]["owner"]; owner != "org-root/ls-bar/ether-eth0" {
+		t.Errorf("%s expected; got %s", "org-root/ls-bar/ether-eth0", owner)
+	}
+}
+
+func TestIsVnicDn(t *testing.T) {
+	for _, owner := range []string{"org-root/ls-foo/ether-eth0", "org-root/org-a/ls-foo/ether-eth0"} {
+		if !isVnicDn(owner) {
+			t.Errorf("%s expected to be the DN of a vNIC", owner)
+		}
+	}
+
+	for _, owner := range []string{"", "www", "org-root/ls-foo", "org-root/ls-/ether-eth0", "org-root/ls-foo/ether-", "lan-conn-pol-x/ether-eth0"} {
+		if isVnicDn(owner) {
+			t.Errorf("%s expected not to be the DN of a vNIC", owner)
+		}
+	}
+}
+
+func TestReconcileSharedInventory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A ucs_ip_allocation of the ip_pool named after eth0 shares its
+	// inventory with the vNICs called eth0.
+	allocator := ipman.NewFileAllocator(dir)
+	pool, err := vnicPool("eth0", "10.0.1.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := allocator.Allocate(pool, "org-root/ls-foo/ether-eth0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := allocator.Allocate(pool, "vip"); err != nil {
+		t.Fatal(err)
+	}
+
+	live := []ipman.Allocation{{Owner: "org-root/ls-foo/ether-eth0"}}
+	report, err := ipman.Reconcile(allocator, pool, live, isVnicDn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Clean() {
+		t.Errorf("clean report expected; got %+v", report)
+	}
+}
@@ -0,0 +1,217 @@
+package ipman
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+)
+
+type (
+	// Pool is a range of IPs handed out by an Allocator. Pools are told apart
+	// by their name, e.g. the name of the vNIC the IPs are assigned to.
+	// IPs are handed out from Network, optionally narrowed down to the
+	// [Start, End] range, never handing out the Gateway, Reserved IPs nor
+	// those in the Excluded networks (e.g. VIPs or DHCP ranges).
+	// DNSServers and Domain are not used for allocating; they are handed
+	// to the hosts along with their IPs.
+	Pool struct {
+		Name       string
+		Network    *net.IPNet
+		Gateway    net.IP
+		DNSServers []net.IP
+		Domain     string
+		Start      net.IP
+		End        net.IP
+		Reserved   []net.IP
+		Excluded   []*net.IPNet
+	}
+
+	// Allocator hands out and takes back the IPs of a pool. Implementations
+	// must be safe for concurrent use.
+	Allocator interface {
+		// Allocates a free IP of the pool on behalf of owner, e.g. the DN
+		// of a vNIC.
+		Allocate(pool *Pool, owner string) (net.IP, error)
+		// Records the given IP of the pool as allocated to owner, e.g.
+		// an IP derived from a MAC address. Claiming an IP allocated to
+		// another owner is an error.
+		Claim(pool *Pool, ip net.IP, owner string) error
+		// Gives an IP back to the pool. Releasing an IP which is not
+		// allocated is a no-op.
+		Release(pool *Pool, ip net.IP) error
+		// Returns the allocation of the IP in the pool or nil if the IP
+		// is not allocated.
+		Lookup(pool *Pool, ip net.IP) (*Allocation, error)
+		// Returns every allocation of the pool.
+		List(pool *Pool) ([]Allocation, error)
+	}
+
+	// FileAllocator keeps the IPs of each pool in a flat text file named
+	// inventory-<pool name> within Dir (the working directory if blank).
+	FileAllocator struct {
+		Dir string
+	}
+)
+
+// Checks that the pool has a network and that its gateway and range lie
+// within it.
+func (p *Pool) Validate() error {
+	if p.Network == nil {
+		return fmt.Errorf("Pool %s has no network to allocate IPs from", p.Name)
+	}
+
+	if p.Gateway != nil && !p.Network.Contains(p.Gateway) {
+		return fmt.Errorf("Gateway of pool %s: %s does not belong to %s", p.Name, p.Gateway, p.Network)
+	}
+
+	for _, ip := range []net.IP{p.Start, p.End} {
+		if ip != nil && !p.Network.Contains(ip) {
+			return fmt.Errorf("Range of pool %s: %s does not belong to %s", p.Name, ip, p.Network)
+		}
+	}
+
+	if p.Start != nil && p.End != nil && bytes.Compare(sameLength(p.Start, p.Network.IP), sameLength(p.End, p.Network.IP)) > 0 {
+		return fmt.Errorf("Range of pool %s: %s comes after %s", p.Name, p.Start, p.End)
+	}
+	return nil
+}
+
+// Returns the first and last IPs the pool can hand out: the host range of
+// its network (see HostRange) narrowed down to Start and End.
+func (p *Pool) Range() (first, last net.IP) {
+	first, last = HostRange(p.Network)
+	if p.Start != nil {
+		if start := sameLength(p.Start, first); bytes.Compare(start, first) > 0 {
+			first = start
+		}
+	}
+
+	if p.End != nil {
+		if end := sameLength(p.End, last); bytes.Compare(end, last) < 0 {
+			last = end
+		}
+	}
+	return
+}
+
+// Returns the lowest IP of the pool which is neither used, reserved nor
+// excluded. This fills the holes left by released IPs before growing into
+// the rest of the pool. Used IPs outside of the pool's network are ignored.
+// Returns a *PoolExhaustedError rather than leaving the pool.
+func (p *Pool) NextFreeIP(used []net.IP) (net.IP, error) {
+	first, last := p.Range()
+
+	// The first free IP is at most len(taken) IPs away from the first one
+	// (excluded networks are skipped as a whole), which keeps the search
+	// cheap even for large networks.
+	taken := make(map[string]bool, len(used)+len(p.Reserved)+1)
+	for _, ips := range [][]net.IP{used, p.Reserved, {p.Gateway}} {
+		for _, ip := range ips {
+			if ip != nil && p.Network.Contains(ip) {
+				taken[ip.String()] = true
+			}
+		}
+	}
+
+	candidate := first
+	for p.Network.Contains(candidate) && bytes.Compare(candidate, last) <= 0 {
+		if excluded := p.excluding(candidate); excluded != nil {
+			next := NextIP(sameLength(lastIP(excluded), candidate))
+			if bytes.Compare(next, candidate) <= 0 {
+				break
+			}
+			candidate = next
+			continue
+		}
+
+		if !taken[candidate.String()] {
+			return candidate, nil
+		}
+		candidate = NextIP(candidate)
+	}
+	return nil, &PoolExhaustedError{Network: p.Network}
+}
+
+// Checks that ip is one the pool could hand out: it lies within the
+// pool's range (so it is neither the network nor the broadcast address) and
+// is neither the gateway, reserved nor excluded. Whether it is used already
+// is up to the inventory.
+func (p *Pool) CheckIP(ip net.IP) error {
+	if !p.Network.Contains(ip) {
+		return fmt.Errorf("%s does not belong to %s", ip, p.Network)
+	}
+
+	ip = sameLength(ip, p.Network.IP)
+	if first, last := HostRange(p.Network); bytes.Compare(ip, first) < 0 || bytes.Compare(ip, last) > 0 {
+		return fmt.Errorf("%s is the network or broadcast address of %s", ip, p.Network)
+	}
+
+	if first, last := p.Range(); bytes.Compare(ip, first) < 0 || bytes.Compare(ip, last) > 0 {
+		return fmt.Errorf("%s lies outside of the range %s-%s of pool %s", ip, first, last, p.Name)
+	}
+
+	if p.Gateway != nil && p.Gateway.Equal(ip) {
+		return fmt.Errorf("%s is the gateway of pool %s", ip, p.Name)
+	}
+
+	for _, reserved := range p.Reserved {
+		if reserved.Equal(ip) {
+			return fmt.Errorf("%s is reserved in pool %s", ip, p.Name)
+		}
+	}
+
+	if excluded := p.excluding(ip); excluded != nil {
+		return fmt.Errorf("%s is excluded from pool %s by %s", ip, p.Name, excluded)
+	}
+	return nil
+}
+
+// Returns the excluded network ip belongs to, if any.
+func (p *Pool) excluding(ip net.IP) *net.IPNet {
+	for _, excluded := range p.Excluded {
+		if excluded.Contains(ip) {
+			return excluded
+		}
+	}
+	return nil
+}
+
+// Returns a FileAllocator keeping its inventory files in dir.
+func NewFileAllocator(dir string) *FileAllocator {
+	return &FileAllocator{Dir: dir}
+}
+
+func (a *FileAllocator) Allocate(pool *Pool, owner string) (net.IP, error) {
+	return AllocateIP(a.inventoryFile(pool), pool, owner)
+}
+
+func (a *FileAllocator) Claim(pool *Pool, ip net.IP, owner string) error {
+	return ClaimIP(a.inventoryFile(pool), pool, ip, owner)
+}
+
+func (a *FileAllocator) Release(pool *Pool, ip net.IP) error {
+	return ReleaseIP(a.inventoryFile(pool), ip)
+}
+
+func (a *FileAllocator) Lookup(pool *Pool, ip net.IP) (*Allocation, error) {
+	allocations, err := a.List(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range allocations {
+		if allocations[i].IP.Equal(ip) {
+			return &allocations[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (a *FileAllocator) List(pool *Pool) ([]Allocation, error) {
+	return Allocations(a.inventoryFile(pool))
+}
+
+func (a *FileAllocator) inventoryFile(pool *Pool) string {
+	return filepath.Join(a.Dir, "inventory-"+pool.Name)
+}
@@ -0,0 +1,164 @@
+package ipman
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	utils "github.com/ContainerSolutions/go-utils"
+)
+
+func TestFileAllocator(t *testing.T) {
+	inventoryFile := "testdata/inventory-eth0"
+	resetInventory(t, inventoryFile, []byte("10.0.1.1\n"))
+	defer os.Remove(inventoryFile)
+	defer os.Remove(inventoryFile + ".lock")
+
+	_, network, err := net.ParseCIDR("10.0.1.0/24")
+	utils.FailOnError(t, err)
+
+	var allocator Allocator = NewFileAllocator("testdata")
+	pool := &Pool{
+		Name:     "eth0",
+		Network:  network,
+		Reserved: []net.IP{net.ParseIP("10.0.1.2")},
+	}
+
+	ip, err := allocator.Allocate(pool, "org-root/ls-foo/ether-eth0")
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.1.3"; ip.String() != expected {
+		t.Errorf("%s expected; got %s", expected, ip)
+	}
+
+	allocation, err := allocator.Lookup(pool, ip)
+	utils.FailOnError(t, err)
+
+	if allocation == nil {
+		t.Fatalf("%s expected to be allocated", ip)
+	}
+
+	if owner := "org-root/ls-foo/ether-eth0"; allocation.Owner != owner {
+		t.Errorf("%s expected; got %s", owner, allocation.Owner)
+	}
+
+	allocation, err = allocator.Lookup(pool, net.ParseIP("10.0.1.200"))
+	utils.FailOnError(t, err)
+
+	if allocation != nil {
+		t.Errorf("nil expected; got %v", allocation)
+	}
+
+	err = allocator.Release(pool, net.ParseIP("10.0.1.1"))
+	utils.FailOnError(t, err)
+
+	allocations, err := allocator.List(pool)
+	utils.FailOnError(t, err)
+
+	if len(allocations) != 1 || !allocations[0].IP.Equal(ip) {
+		t.Errorf("[%s] expected; got %v", ip, allocations)
+	}
+}
+
+func TestFileAllocatorWithoutNetwork(t *testing.T) {
+	allocator := NewFileAllocator("testdata")
+	_, err := allocator.Allocate(&Pool{Name: "eth0"}, "org-root/ls-foo/ether-eth0")
+	if err == nil {
+		t.Errorf("error expected; got nil")
+	}
+}
+
+func TestFileAllocatorListEmptyPool(t *testing.T) {
+	allocator := NewFileAllocator("testdata")
+	allocations, err := allocator.List(&Pool{Name: "i-do-not-exist"})
+	utils.FailOnError(t, err)
+
+	if allocations == nil || len(allocations) != 0 {
+		t.Errorf("empty list expected; got %v", allocations)
+	}
+}
+
+func TestPoolNextFreeIP(t *testing.T) {
+	tests := []struct {
+		Pool     *Pool
+		Used     []string
+		Expected string
+	}{
+		{&Pool{Start: net.ParseIP("10.0.1.11")}, []string{}, "10.0.1.11"},
+		{&Pool{Start: net.ParseIP("10.0.1.11")}, []string{"10.0.1.11", "10.0.1.1"}, "10.0.1.12"},
+		{&Pool{End: net.ParseIP("10.0.1.2")}, []string{"10.0.1.1"}, "10.0.1.2"},
+		{&Pool{Excluded: []*net.IPNet{mustParseCIDR(t, "10.0.1.0/28")}}, []string{}, "10.0.1.16"},
+		{&Pool{Excluded: []*net.IPNet{mustParseCIDR(t, "10.0.1.0/28"), mustParseCIDR(t, "10.0.1.16/32")}}, []string{"10.0.1.17"}, "10.0.1.18"},
+		{&Pool{Reserved: []net.IP{net.ParseIP("10.0.1.11")}, Start: net.ParseIP("10.0.1.11")}, []string{}, "10.0.1.12"},
+	}
+
+	for _, test := range tests {
+		test.Pool.Network = mustParseCIDR(t, "10.0.1.0/24")
+		ip, err := test.Pool.NextFreeIP(parseIPs(test.Used))
+		utils.FailOnError(t, err)
+
+		if ip.String() != test.Expected {
+			t.Errorf("%s expected; got %s", test.Expected, ip)
+		}
+	}
+}
+
+func TestPoolNextFreeIPExhausted(t *testing.T) {
+	pools := []*Pool{
+		{Start: net.ParseIP("10.0.1.253"), End: net.ParseIP("10.0.1.253")},
+		{Excluded: []*net.IPNet{mustParseCIDR(t, "10.0.1.0/25"), mustParseCIDR(t, "10.0.1.128/25")}},
+		{Excluded: []*net.IPNet{mustParseCIDR(t, "0.0.0.0/0")}},
+	}
+
+	for _, pool := range pools {
+		pool.Network = mustParseCIDR(t, "10.0.1.0/24")
+		_, err := pool.NextFreeIP(parseIPs([]string{"10.0.1.253"}))
+		if !IsPoolExhausted(err) {
+			t.Errorf("pool exhausted error expected; got %v", err)
+		}
+	}
+}
+
+func TestPoolValidate(t *testing.T) {
+	valid := &Pool{Network: mustParseCIDR(t, "10.0.1.0/24"), Start: net.ParseIP("10.0.1.11"), End: net.ParseIP("10.0.1.200")}
+	utils.FailOnError(t, valid.Validate())
+
+	invalid := []*Pool{
+		{},
+		{Network: mustParseCIDR(t, "10.0.1.0/24"), Start: net.ParseIP("10.0.2.11")},
+		{Network: mustParseCIDR(t, "10.0.1.0/24"), End: net.ParseIP("10.0.2.11")},
+		{Network: mustParseCIDR(t, "10.0.1.0/24"), Start: net.ParseIP("10.0.1.200"), End: net.ParseIP("10.0.1.11")},
+	}
+
+	for _, pool := range invalid {
+		if err := pool.Validate(); err == nil {
+			t.Errorf("error expected for %+v; got nil", pool)
+		}
+	}
+}
+
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	utils.FailOnError(t, err)
+	return network
+}
+
+func TestPoolWithGateway(t *testing.T) {
+	pool := &Pool{
+		Network: mustParseCIDR(t, "10.0.1.0/24"),
+		Gateway: net.ParseIP("10.0.1.1"),
+	}
+	utils.FailOnError(t, pool.Validate())
+
+	ip, err := pool.NextFreeIP(nil)
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.1.2"; ip.String() != expected {
+		t.Errorf("%s expected; got %s", expected, ip)
+	}
+
+	pool.Gateway = net.ParseIP("10.0.2.1")
+	if err := pool.Validate(); err == nil {
+		t.Errorf("error expected for a gateway outside of the network; got nil")
+	}
+}
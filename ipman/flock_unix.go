@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package ipman
+
+import (
+	"os"
+	"syscall"
+)
+
+// Opens (creating it if needed) and exclusively locks the given file,
+// blocking until any other process holding the lock releases it.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Releases a lock taken with lockFile.
+func unlockFile(file *os.File) error {
+	defer file.Close()
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
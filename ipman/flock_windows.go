@@ -0,0 +1,50 @@
+//go:build windows
+// +build windows
+
+package ipman
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const lockfileExclusiveLock = 0x2
+
+// Opens (creating it if needed) and exclusively locks the given file with
+// LockFileEx, blocking until any other process holding the lock releases
+// it. As with flock, Windows releases the lock when the process holding it
+// exits, even if it crashes.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Locks the first byte of the file.
+	overlapped := new(syscall.Overlapped)
+	r, _, err := procLockFileEx.Call(file.Fd(), lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(overlapped)))
+	if r == 0 {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Releases a lock taken with lockFile.
+func unlockFile(file *os.File) error {
+	defer file.Close()
+
+	overlapped := new(syscall.Overlapped)
+	r, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(overlapped)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
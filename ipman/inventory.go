@@ -0,0 +1,171 @@
+package ipman
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Replaced by tests to control the allocation time of IPs.
+var now = time.Now
+
+// Serialises reading and updating inventory files within the process.
+// Concurrent terraform runs are kept apart by an advisory lock on a file
+// next to the inventory (see lockFile).
+var inventoryMutex = sync.Mutex{}
+
+// Allocation is an IP recorded in an inventory file along with the owner
+// it was handed out to and when. Inventories written by older versions
+// only hold IPs, in which case Owner is blank and Allocated is zero.
+type Allocation struct {
+	IP        net.IP
+	Owner     string
+	Allocated time.Time
+}
+
+// Returns an array of IPs (net.IP) from the given inventory file path.
+// If the given inventory file does not exist it'll return an empty array.
+func Inventory(inventoryFile string) ([]net.IP, error) {
+	allocations, err := Allocations(inventoryFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, len(allocations))
+	for i, a := range allocations {
+		ips[i] = a.IP
+	}
+	return ips, nil
+}
+
+// Returns the allocations recorded in the given inventory file.
+// If the given inventory file does not exist it'll return an empty array,
+// without creating its lock file either.
+func Allocations(inventoryFile string) ([]Allocation, error) {
+	if _, err := os.Stat(inventoryFile); os.IsNotExist(err) {
+		return make([]Allocation, 0), nil
+	}
+
+	var allocations []Allocation
+	err := withInventoryLock(inventoryFile, func() (err error) {
+		allocations, err = readInventory(inventoryFile)
+		return
+	})
+	return allocations, err
+}
+
+// Takes an IP (net.IP) and adds it to the given inventory file path
+// without an owner.
+func SaveIP(inventoryFile string, ip net.IP) error {
+	return withInventoryLock(inventoryFile, func() error {
+		allocations, err := readInventory(inventoryFile)
+		if err != nil {
+			return err
+		}
+		return writeInventory(inventoryFile, append(allocations, Allocation{IP: ip}))
+	})
+}
+
+// Runs fn while holding both the in-process and the advisory file lock of
+// the given inventory file. The lock file is left behind on purpose:
+// removing it would let another process lock a file nobody else sees.
+func withInventoryLock(inventoryFile string, fn func() error) error {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+
+	lock, err := lockFile(inventoryFile + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+
+	return fn()
+}
+
+// Reads the allocations from the given inventory file. Each line holds an
+// IP optionally followed by its owner and allocation time (RFC 3339),
+// separated by tabs. Blank lines are ignored.
+func readInventory(inventoryFile string) ([]Allocation, error) {
+	data, err := ioutil.ReadFile(inventoryFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]Allocation, 0), nil
+		}
+		return nil, err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	allocations := make([]Allocation, 0, len(lines))
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		a := Allocation{IP: net.ParseIP(fields[0])}
+		if a.IP == nil {
+			return nil, fmt.Errorf("%s:%d: invalid IP %q", inventoryFile, i+1, fields[0])
+		}
+
+		if len(fields) > 1 {
+			a.Owner = fields[1]
+		}
+
+		if len(fields) > 2 && fields[2] != "" {
+			a.Allocated, err = time.Parse(time.RFC3339, fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", inventoryFile, i+1, err)
+			}
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, nil
+}
+
+// Replaces the given inventory file with the allocations. The new content
+// is written to a temporary file which is then renamed over the inventory,
+// so that a crash never leaves a half-written inventory behind.
+func writeInventory(inventoryFile string, allocations []Allocation) error {
+	var data bytes.Buffer
+	for _, a := range allocations {
+		data.WriteString(a.IP.String())
+		if a.Owner != "" || !a.Allocated.IsZero() {
+			data.WriteString("\t" + a.Owner + "\t")
+			if !a.Allocated.IsZero() {
+				data.WriteString(a.Allocated.Format(time.RFC3339))
+			}
+		}
+		data.WriteString("\n")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(inventoryFile), filepath.Base(inventoryFile)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data.Bytes())
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), inventoryFile)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
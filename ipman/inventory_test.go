@@ -0,0 +1,166 @@
+package ipman
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	utils "github.com/ContainerSolutions/go-utils"
+)
+
+func withFrozenTime(t time.Time) func() {
+	original := now
+	now = func() time.Time { return t }
+	return func() { now = original }
+}
+
+func TestAllocateIPRecordsOwner(t *testing.T) {
+	defer withFrozenTime(time.Date(2017, 3, 14, 15, 9, 26, 0, time.UTC))()
+
+	inventoryFile, cleanup := tempInventory(t, []byte("10.0.1.1\n"))
+	defer cleanup()
+
+	_, network, err := net.ParseCIDR("10.0.1.0/24")
+	utils.FailOnError(t, err)
+
+	ip, err := AllocateIP(inventoryFile, &Pool{Network: network}, "org-root/ls-foo/ether-eth0")
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.1.2"; ip.String() != expected {
+		t.Errorf("%s expected; got %s", expected, ip)
+	}
+
+	expected := "10.0.1.1\n10.0.1.2\torg-root/ls-foo/ether-eth0\t2017-03-14T15:09:26Z\n"
+	actual, err := ioutil.ReadFile(inventoryFile)
+	utils.FailOnError(t, err)
+
+	if !bytes.Equal(actual, []byte(expected)) {
+		t.Errorf("expected:\n%s\n*****\ngot:\n%s\n", expected, actual)
+	}
+}
+
+func TestAllocations(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, []byte("10.0.1.1\n\n10.0.1.2\torg-root/ls-foo/ether-eth0\t2017-03-14T15:09:26Z\n10.0.1.3\t\t2017-03-14T15:09:27Z\n"))
+	defer cleanup()
+
+	allocations, err := Allocations(inventoryFile)
+	utils.FailOnError(t, err)
+
+	if len(allocations) != 3 {
+		t.Fatalf("3 allocations expected; got %d", len(allocations))
+	}
+
+	if a := allocations[0]; a.IP.String() != "10.0.1.1" || a.Owner != "" || !a.Allocated.IsZero() {
+		t.Errorf("10.0.1.1 without owner nor time expected; got %v", a)
+	}
+
+	if owner := "org-root/ls-foo/ether-eth0"; allocations[1].Owner != owner {
+		t.Errorf("%s expected; got %s", owner, allocations[1].Owner)
+	}
+
+	allocated := time.Date(2017, 3, 14, 15, 9, 26, 0, time.UTC)
+	if !allocations[1].Allocated.Equal(allocated) {
+		t.Errorf("%s expected; got %s", allocated, allocations[1].Allocated)
+	}
+
+	if allocations[2].Owner != "" {
+		t.Errorf("blank owner expected; got %s", allocations[2].Owner)
+	}
+}
+
+func TestAllocationsInvalidIP(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, []byte("10.0.1.1\n10.0.1.\n"))
+	defer cleanup()
+
+	_, err := Allocations(inventoryFile)
+	if err == nil {
+		t.Errorf("error expected for a corrupted inventory; got nil")
+	}
+}
+
+func TestAllocationsNonExistingFileLeavesNoLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+	defer os.RemoveAll(dir)
+
+	allocations, err := Allocations(filepath.Join(dir, "inventory-eth0"))
+	utils.FailOnError(t, err)
+
+	if len(allocations) != 0 {
+		t.Errorf("no allocations expected; got %v", allocations)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	utils.FailOnError(t, err)
+
+	if len(files) != 0 {
+		t.Errorf("no files expected in %s; got %v", dir, files)
+	}
+}
+
+func TestWriteInventoryLeavesNoTemporaryFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+	defer os.RemoveAll(dir)
+
+	inventoryFile := filepath.Join(dir, "inventory-eth0")
+	err = writeInventory(inventoryFile, []Allocation{{IP: net.ParseIP("10.0.1.1")}})
+	utils.FailOnError(t, err)
+
+	err = writeInventory(inventoryFile, []Allocation{{IP: net.ParseIP("10.0.1.2")}})
+	utils.FailOnError(t, err)
+
+	files, err := ioutil.ReadDir(dir)
+	utils.FailOnError(t, err)
+
+	if len(files) != 1 || files[0].Name() != "inventory-eth0" {
+		t.Errorf("only inventory-eth0 expected in %s; got %v", dir, files)
+	}
+
+	actual, err := ioutil.ReadFile(inventoryFile)
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.1.2\n"; string(actual) != expected {
+		t.Errorf("expected:\n%s\n*****\ngot:\n%s\n", expected, actual)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "inventory-eth0.lock")
+
+	lock, err := lockFile(path)
+	utils.FailOnError(t, err)
+
+	locked := make(chan struct{})
+	go func() {
+		other, err := lockFile(path)
+		if err != nil {
+			t.Error(err)
+			close(locked)
+			return
+		}
+		close(locked)
+		unlockFile(other)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatalf("lock expected to be held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	utils.FailOnError(t, unlockFile(lock))
+
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Errorf("lock expected to be released")
+	}
+}
@@ -1,122 +1,247 @@
 package ipman
 
 import (
-	"bytes"
+	"fmt"
 	"net"
-	"os"
 )
 
-// Generates a new IP. The criteria for generating a new IP with this method is
-// the following:
-// Fetches the list of IPs from the given inventory file. If there is at least
-// one IP in that list it will use it as its base to generate the next one.
-// If there are no IPs in the inventory file then it will generate a new IP
-// based on the given CIDR.
-// Independent of the method used for generating the new IP, this method will
-// save the whole list of IPs held in memory into the given inventory file.
-func GenerateIP(inventoryFile, cidr string) (net.IP, error) {
-	var ip net.IP
+// PoolExhaustedError is returned when every usable IP of a network has
+// already been handed out.
+type PoolExhaustedError struct {
+	Network *net.IPNet
+}
 
-	inventory, err := Inventory(inventoryFile)
+func (e *PoolExhaustedError) Error() string {
+	return fmt.Sprintf("No free IPs left in %s", e.Network)
+}
+
+// Determines whether err was caused by a network running out of IPs.
+func IsPoolExhausted(err error) bool {
+	_, ok := err.(*PoolExhaustedError)
+	return ok
+}
+
+// Generates a new IP within the given CIDR and saves it into the given
+// inventory file without an owner. See AllocateIP.
+func GenerateIP(inventoryFile, cidr string, reserved ...net.IP) (net.IP, error) {
+	_, network, err := net.ParseCIDR(cidr)
 	if err != nil {
 		return nil, err
 	}
+	return AllocateIP(inventoryFile, &Pool{Network: network, Reserved: reserved}, "")
+}
 
-	// If there is an ip already in the inventory then generate a new one based on that
-	if len(inventory) > 0 {
-		lastIP := inventory[len(inventory)-1]
-		ip = NextIP(lastIP)
-	} else {
-		x, _, err := net.ParseCIDR(cidr)
+// Allocates a new IP of the pool on behalf of owner (e.g. the DN of a vNIC)
+// and saves it into the given inventory file, which holds every IP handed
+// out so far.
+// The new IP is the lowest one of the pool which is not in the inventory
+// (see Pool.NextFreeIP), so released IPs are reused before handing out new
+// ones; IPs from other networks are ignored.
+// Returns a *PoolExhaustedError if there are no IPs left.
+func AllocateIP(inventoryFile string, pool *Pool, owner string) (net.IP, error) {
+	err := pool.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var ip net.IP
+	err = withInventoryLock(inventoryFile, func() error {
+		allocations, err := readInventory(inventoryFile)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		ip = NextIP(x)
-	}
 
-	// Now that the IP has been generated, let's save it to the inventory file.
-	err = SaveIP(inventoryFile, ip)
+		used := make([]net.IP, len(allocations))
+		for i, a := range allocations {
+			used[i] = a.IP
+		}
+
+		ip, err = pool.NextFreeIP(used)
+		if err != nil {
+			return err
+		}
+
+		// Now that the IP has been generated, let's save it to the inventory file.
+		allocations = append(allocations, Allocation{
+			IP:        ip,
+			Owner:     owner,
+			Allocated: now().UTC(),
+		})
+		return writeInventory(inventoryFile, allocations)
+	})
 	if err != nil {
 		return nil, err
 	}
-
 	return ip, nil
 }
 
-// NOTE:
-// Unfortunatelly this implementation only supports up to 254 IPs in a single subnetwork.
-// For ALPHA release this is ok but we must figure out a way to make this function to return
-// IPs that do not end on .255 nor .0.
-// I haven't been able yet to figure out a way to make it work. I'll leave this here for a bit
-// until my brain comes back to me with a solution.
-func NextIP(ip net.IP) net.IP {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
+// Records ip, which must be one the pool could hand out (see
+// Pool.CheckIP), as allocated to owner in the given inventory file. This is
+// how IPs which are not picked by ipman (e.g. pinned in the configuration or
+// derived from a MAC address, see EUI64) make it into the inventory.
+// Claiming an IP again for the same owner is a no-op; claiming an IP
+// allocated to someone else is an error, unless it was recorded without an
+// owner by an older version, in which case the owner is filled in.
+func ClaimIP(inventoryFile string, pool *Pool, ip net.IP, owner string) error {
+	err := pool.Validate()
+	if err != nil {
+		return err
 	}
-	return ip
-}
 
-// Returns an array of IPs (net.IP) from the given inventory file path.
-// If the given inventory file does not exist it'll return an empty array.
-func Inventory(inventoryFile string) ([]net.IP, error) {
-	// Early return an empty array if the inventory file does not exist.
-	file, err := os.Open(inventoryFile)
+	err = pool.CheckIP(ip)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return make([]net.IP, 0), nil
-		} else {
-			return nil, err
-		}
+		return err
 	}
-	defer file.Close()
 
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return nil, err
+	return withInventoryLock(inventoryFile, func() error {
+		allocations, err := readInventory(inventoryFile)
+		if err != nil {
+			return err
+		}
+
+		for i, a := range allocations {
+			if !a.IP.Equal(ip) {
+				continue
+			}
+
+			switch a.Owner {
+			case owner:
+				return nil
+			case "":
+				allocations[i].Owner = owner
+				return writeInventory(inventoryFile, allocations)
+			}
+			return fmt.Errorf("%s is already allocated to %q", ip, a.Owner)
+		}
+
+		allocations = append(allocations, Allocation{
+			IP:        ip,
+			Owner:     owner,
+			Allocated: now().UTC(),
+		})
+		return writeInventory(inventoryFile, allocations)
+	})
+}
+
+// Returns the IPv6 address of the given network whose interface identifier
+// is the modified EUI-64 derived from mac (RFC 4291, appendix A), which is
+// what hosts configure through stateless autoconfiguration.
+// The network's prefix must be /64 or shorter.
+func EUI64(network *net.IPNet, mac net.HardwareAddr) (net.IP, error) {
+	ones, bits := network.Mask.Size()
+	if bits != 8*net.IPv6len {
+		return nil, fmt.Errorf("EUI-64 addresses require an IPv6 network; got %s", network)
 	}
-	b := make([]byte, fileInfo.Size())
-	_, err = file.Read(b)
-	if err != nil {
-		return nil, err
+
+	if ones > 64 {
+		return nil, fmt.Errorf("EUI-64 addresses require a /64 or shorter prefix; got %s", network)
 	}
 
-	// If the inventory file is empty the bytes array will contain only one element (EOL).
-	if len(b) <= 1 {
-		return nil, nil
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("EUI-64 addresses require a 48-bit MAC address; got %s", mac)
 	}
 
-	// Trim the EOL character which gets appended by the `ioutil.ReadFile` function
-	if b[len(b)-1] == 10 {
-		b = b[0 : len(b)-1]
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, network.IP.Mask(network.Mask))
+	ip[8] = mac[0] ^ 0x02
+	ip[9] = mac[1]
+	ip[10] = mac[2]
+	ip[11] = 0xff
+	ip[12] = 0xfe
+	ip[13] = mac[3]
+	ip[14] = mac[4]
+	ip[15] = mac[5]
+	return ip, nil
+}
+
+// Returns the lowest IP of network which is neither used nor reserved,
+// skipping the network and broadcast addresses. See Pool.NextFreeIP.
+func NextFreeIP(network *net.IPNet, used []net.IP, reserved ...net.IP) (net.IP, error) {
+	pool := &Pool{Network: network, Reserved: reserved}
+	return pool.NextFreeIP(used)
+}
+
+// Removes the given IP from the inventory file so that it can be handed
+// out again. Releasing an IP which is not in the inventory is a no-op.
+func ReleaseIP(inventoryFile string, ip net.IP) error {
+	return withInventoryLock(inventoryFile, func() error {
+		allocations, err := readInventory(inventoryFile)
+		if err != nil {
+			return err
+		}
+
+		kept := make([]Allocation, 0, len(allocations))
+		for _, a := range allocations {
+			if !a.IP.Equal(ip) {
+				kept = append(kept, a)
+			}
+		}
+
+		if len(kept) == len(allocations) {
+			return nil
+		}
+		return writeInventory(inventoryFile, kept)
+	})
+}
+
+// Returns the first and last IPs of network which can be handed out to
+// hosts, that is all of them but the network and broadcast addresses (for
+// IPv6, the Subnet-Router anycast and the all-ones addresses).
+// Point-to-point networks (/31 and /32 or /127 and /128) have no such
+// addresses so all of their IPs are usable.
+func HostRange(network *net.IPNet) (first, last net.IP) {
+	first = network.IP.Mask(network.Mask)
+	last = lastIP(network)
+
+	ones, bits := network.Mask.Size()
+	if bits-ones > 1 {
+		first = NextIP(first)
+		last = prevIP(last)
 	}
+	return
+}
 
-	// 10 is unicode for \r.
-	// IPs are expected to be stored in the inventory file one per line
-	// and that is why the separation character expected is return (\r).
-	unparsedIPs := bytes.Split(b, []byte{10})
-	ips := make([]net.IP, len(unparsedIPs))
+// Returns the last IP of network, i.e. its broadcast address for IPv4.
+func lastIP(network *net.IPNet) net.IP {
+	first := network.IP.Mask(network.Mask)
+	last := make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^network.Mask[i]
+	}
+	return last
+}
 
-	// Loop over the IPs which at this stage are still strings and
-	// convert them into actual net.IP objects.
-	for i, unparsedIP := range unparsedIPs {
-		ips[i] = net.ParseIP(string(unparsedIP))
+// Converts ip to the same representation (4 or 16 bytes) as ref so that
+// both can be compared byte by byte.
+func sameLength(ip, ref net.IP) net.IP {
+	if len(ref) == net.IPv4len {
+		return ip.To4()
 	}
-	return ips, nil
+	return ip.To16()
 }
 
-// Takes an IP (net.IP) and dumps ip into the given
-// inventory file path.
-func SaveIP(inventoryFile string, ip net.IP) error {
-	file, err := os.OpenFile(inventoryFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
-	if err != nil {
-		return err
+// Returns the IP following the given one. Network boundaries are not taken
+// into account; see NextFreeIP for that.
+func NextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for j := len(next) - 1; j >= 0; j-- {
+		next[j]++
+		if next[j] > 0 {
+			break
+		}
 	}
-	defer file.Close()
+	return next
+}
 
-	data := []byte(ip.String() + "\n")
-	_, err = file.Write(data)
-	return err
+func prevIP(ip net.IP) net.IP {
+	prev := make(net.IP, len(ip))
+	copy(prev, ip)
+	for j := len(prev) - 1; j >= 0; j-- {
+		prev[j]--
+		if prev[j] < 255 {
+			break
+		}
+	}
+	return prev
 }
@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	utils "github.com/ContainerSolutions/go-utils"
@@ -22,6 +24,17 @@ func resetInventory(t *testing.T, inventoryFile string, inventory []byte) {
 	}
 }
 
+// Returns the path of an inventory file holding inventory in a new temporary
+// directory, along with a function removing the directory.
+func tempInventory(t *testing.T, inventory []byte) (string, func()) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+
+	inventoryFile := filepath.Join(dir, "inventory")
+	resetInventory(t, inventoryFile, inventory)
+	return inventoryFile, func() { os.RemoveAll(dir) }
+}
+
 func TestGenerateIPFromCIDR(t *testing.T) {
 	inventory := make([]byte, 0)
 	inventoryFile := "testdata/dummy-inventory.txt"
@@ -62,7 +75,7 @@ func TestGenerateIPFromInventoryFile(t *testing.T) {
 	inventoryFile := "testdata/dummy-inventory.txt"
 	resetInventory(t, inventoryFile, inventory)
 
-	ip, err := GenerateIP(inventoryFile, "127.0.0.1/32")
+	ip, err := GenerateIP(inventoryFile, "10.0.0.0/24")
 	utils.FailOnError(t, err)
 	actual := ip.String()
 
@@ -87,24 +100,171 @@ func TestGenerateIPFromInventoryFile(t *testing.T) {
 }
 
 func TestNextIP(t *testing.T) {
-	t.Skip("Skipping until figuring out how to skip .0 and .255 IPs")
-	expected := "10.0.1.1"
-	ip := NextIP(net.ParseIP("10.0.1.0"))
-	actual := ip.String()
+	tests := []struct {
+		IP       string
+		Expected string
+	}{
+		{"10.0.1.0", "10.0.1.1"},
+		{"10.0.1.254", "10.0.1.255"},
+		{"10.0.1.255", "10.0.2.0"},
+		{"fe80::ffff", "fe80::1:0"},
+	}
 
-	if actual != expected {
-		t.Errorf("%s expected; got %s", expected, actual)
+	for _, test := range tests {
+		ip := net.ParseIP(test.IP)
+		actual := NextIP(ip).String()
+		if actual != test.Expected {
+			t.Errorf("%s expected; got %s", test.Expected, actual)
+		}
+
+		if ip.String() != test.IP {
+			t.Errorf("%s expected to be left untouched; got %s", test.IP, ip)
+		}
 	}
+}
 
-	expected = "10.0.2.1"
-	ip = NextIP(net.ParseIP("10.0.1.254"))
-	actual = ip.String()
+func TestHostRange(t *testing.T) {
+	tests := []struct {
+		CIDR  string
+		First string
+		Last  string
+	}{
+		{"10.0.1.0/24", "10.0.1.1", "10.0.1.254"},
+		{"10.0.1.77/24", "10.0.1.1", "10.0.1.254"},
+		{"10.0.0.0/16", "10.0.0.1", "10.0.255.254"},
+		{"10.0.1.4/31", "10.0.1.4", "10.0.1.5"},
+		{"10.0.1.4/32", "10.0.1.4", "10.0.1.4"},
+		{"2001:db8::/64", "2001:db8::1", "2001:db8::ffff:ffff:ffff:fffe"},
+	}
 
-	if actual != expected {
-		t.Errorf("%s expected; got %s", expected, actual)
+	for _, test := range tests {
+		_, network, err := net.ParseCIDR(test.CIDR)
+		utils.FailOnError(t, err)
+
+		first, last := HostRange(network)
+		if first.String() != test.First {
+			t.Errorf("%s expected; got %s", test.First, first)
+		}
+
+		if last.String() != test.Last {
+			t.Errorf("%s expected; got %s", test.Last, last)
+		}
+	}
+}
+
+func TestNextFreeIP(t *testing.T) {
+	tests := []struct {
+		CIDR     string
+		Used     []string
+		Reserved []string
+		Expected string
+	}{
+		{"10.0.1.0/24", []string{}, []string{}, "10.0.1.1"},
+		{"10.0.1.0/24", []string{"10.0.1.1", "10.0.1.2"}, []string{}, "10.0.1.3"},
+		{"10.0.1.0/24", []string{"10.0.1.7", "10.0.1.2"}, []string{}, "10.0.1.1"},
+		{"10.0.1.0/24", []string{"10.0.1.1", "10.0.1.2", "10.0.1.4"}, []string{}, "10.0.1.3"},
+		{"10.0.1.0/24", []string{}, []string{"10.0.1.1"}, "10.0.1.2"},
+		{"10.0.1.0/24", []string{"10.0.1.1"}, []string{"10.0.1.2"}, "10.0.1.3"},
+		{"10.0.1.0/24", []string{"10.0.0.1", "10.0.2.200"}, []string{}, "10.0.1.1"},
+		{"10.0.1.0/30", []string{"10.0.1.1"}, []string{}, "10.0.1.2"},
+		{"10.0.1.4/31", []string{"10.0.1.4"}, []string{}, "10.0.1.5"},
+		{"2001:db8::/64", []string{"2001:db8::1"}, []string{}, "2001:db8::2"},
+	}
+
+	for _, test := range tests {
+		_, network, err := net.ParseCIDR(test.CIDR)
+		utils.FailOnError(t, err)
+
+		ip, err := NextFreeIP(network, parseIPs(test.Used), parseIPs(test.Reserved)...)
+		utils.FailOnError(t, err)
+
+		if ip.String() != test.Expected {
+			t.Errorf("%s expected; got %s", test.Expected, ip)
+		}
+	}
+}
+
+func TestNextFreeIPPoolExhausted(t *testing.T) {
+	tests := []struct {
+		CIDR     string
+		Used     []string
+		Reserved []string
+	}{
+		{"10.0.1.0/29", []string{"10.0.1.1", "10.0.1.2", "10.0.1.3", "10.0.1.4", "10.0.1.5", "10.0.1.6"}, []string{}},
+		{"10.0.1.0/30", []string{"10.0.1.1"}, []string{"10.0.1.2"}},
+		{"10.0.1.4/32", []string{"10.0.1.4"}, []string{}},
+		{"255.255.255.255/32", []string{"255.255.255.255"}, []string{}},
+	}
+
+	for _, test := range tests {
+		_, network, err := net.ParseCIDR(test.CIDR)
+		utils.FailOnError(t, err)
+
+		ip, err := NextFreeIP(network, parseIPs(test.Used), parseIPs(test.Reserved)...)
+		if !IsPoolExhausted(err) {
+			t.Errorf("pool exhausted error expected for %s; got %v (%s)", test.CIDR, err, ip)
+		}
+	}
+}
+
+func TestNextFreeIPLargePool(t *testing.T) {
+	_, network, err := net.ParseCIDR("10.0.0.0/16")
+	utils.FailOnError(t, err)
+
+	used := make([]net.IP, 0, 65000)
+	ip := net.ParseIP("10.0.0.0")
+	for i := 0; i < 65000; i++ {
+		ip = NextIP(ip)
+		used = append(used, ip)
+	}
+
+	next, err := NextFreeIP(network, used)
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.253.233"; next.String() != expected {
+		t.Errorf("%s expected; got %s", expected, next)
+	}
+
+	// Crossing the .255/.0 boundary within a /16 is fine.
+	used[254] = net.ParseIP("10.0.200.1")
+	next, err = NextFreeIP(network, used)
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.0.255"; next.String() != expected {
+		t.Errorf("%s expected; got %s", expected, next)
+	}
+}
+
+func TestGenerateIPPoolExhausted(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, []byte("10.0.1.1\n10.0.1.2\n"))
+	defer cleanup()
+
+	_, err := GenerateIP(inventoryFile, "10.0.1.0/30")
+	if !IsPoolExhausted(err) {
+		t.Errorf("pool exhausted error expected; got %v", err)
 	}
 }
 
+func TestGenerateIPSkipsReserved(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, make([]byte, 0))
+	defer cleanup()
+
+	ip, err := GenerateIP(inventoryFile, "10.0.1.0/24", net.ParseIP("10.0.1.1"))
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.1.2"; ip.String() != expected {
+		t.Errorf("%s expected; got %s", expected, ip)
+	}
+}
+
+func parseIPs(ips []string) []net.IP {
+	ret := make([]net.IP, len(ips))
+	for i, ip := range ips {
+		ret[i] = net.ParseIP(ip)
+	}
+	return ret
+}
+
 func TestInventory(t *testing.T) {
 	inventory := []byte("10.0.0.1\n")
 	inventoryFile := "testdata/dummy-inventory.txt"
@@ -173,3 +333,245 @@ func TestSaveIPExistingInventory(t *testing.T) {
 		t.Errorf("expected:\n%s\n*****\ngot:\n%s\n", expected, actual)
 	}
 }
+
+func TestGenerateIPConcurrently(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, make([]byte, 0))
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	ips := make(chan net.IP, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ip, err := GenerateIP(inventoryFile, "10.0.1.0/24")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ips <- ip
+		}()
+	}
+	wg.Wait()
+	close(ips)
+
+	seen := make(map[string]bool)
+	for ip := range ips {
+		if seen[ip.String()] {
+			t.Errorf("%s was handed out more than once", ip)
+		}
+		seen[ip.String()] = true
+	}
+}
+
+func TestReleaseIP(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, []byte("10.0.1.1\n10.0.1.2\n10.0.1.3\n"))
+	defer cleanup()
+
+	err := ReleaseIP(inventoryFile, net.ParseIP("10.0.1.2"))
+	utils.FailOnError(t, err)
+
+	expected := "10.0.1.1\n10.0.1.3\n"
+	actual, err := ioutil.ReadFile(inventoryFile)
+	utils.FailOnError(t, err)
+
+	if !bytes.Equal(actual, []byte(expected)) {
+		t.Errorf("expected:\n%s\n*****\ngot:\n%s\n", expected, actual)
+	}
+
+	// The hole left by the released IP is filled before growing.
+	ip, err := GenerateIP(inventoryFile, "10.0.1.0/24")
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.1.2"; ip.String() != expected {
+		t.Errorf("%s expected; got %s", expected, ip)
+	}
+
+	ip, err = GenerateIP(inventoryFile, "10.0.1.0/24")
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.1.4"; ip.String() != expected {
+		t.Errorf("%s expected; got %s", expected, ip)
+	}
+}
+
+func TestReleaseIPNotInInventory(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, []byte("10.0.1.1\n"))
+	defer cleanup()
+
+	err := ReleaseIP(inventoryFile, net.ParseIP("10.0.1.9"))
+	utils.FailOnError(t, err)
+
+	expected := "10.0.1.1\n"
+	actual, err := ioutil.ReadFile(inventoryFile)
+	utils.FailOnError(t, err)
+
+	if !bytes.Equal(actual, []byte(expected)) {
+		t.Errorf("expected:\n%s\n*****\ngot:\n%s\n", expected, actual)
+	}
+}
+
+func TestReleaseIPLastOne(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, []byte("10.0.1.1\n"))
+	defer cleanup()
+
+	err := ReleaseIP(inventoryFile, net.ParseIP("10.0.1.1"))
+	utils.FailOnError(t, err)
+
+	inventory, err := Inventory(inventoryFile)
+	utils.FailOnError(t, err)
+
+	if len(inventory) != 0 {
+		t.Errorf("empty inventory expected; got %v", inventory)
+	}
+}
+
+func TestEUI64(t *testing.T) {
+	tests := []struct {
+		CIDR     string
+		MAC      string
+		Expected string
+	}{
+		{"2001:db8:1:2::/64", "00:25:B5:00:00:9F", "2001:db8:1:2:225:b5ff:fe00:9f"},
+		{"2001:db8::/48", "02:25:b5:aa:bb:cc", "2001:db8::25:b5ff:feaa:bbcc"},
+		{"fd00:1::5/64", "00:25:b5:00:00:8f", "fd00:1::225:b5ff:fe00:8f"},
+	}
+
+	for _, test := range tests {
+		_, network, err := net.ParseCIDR(test.CIDR)
+		utils.FailOnError(t, err)
+
+		mac, err := net.ParseMAC(test.MAC)
+		utils.FailOnError(t, err)
+
+		ip, err := EUI64(network, mac)
+		utils.FailOnError(t, err)
+
+		if ip.String() != test.Expected {
+			t.Errorf("%s expected; got %s", test.Expected, ip)
+		}
+	}
+}
+
+func TestEUI64InvalidNetwork(t *testing.T) {
+	mac, err := net.ParseMAC("00:25:B5:00:00:9F")
+	utils.FailOnError(t, err)
+
+	for _, cidr := range []string{"10.0.1.0/24", "2001:db8::/96"} {
+		_, network, err := net.ParseCIDR(cidr)
+		utils.FailOnError(t, err)
+
+		_, err = EUI64(network, mac)
+		if err == nil {
+			t.Errorf("error expected for %s; got nil", cidr)
+		}
+	}
+}
+
+func TestClaimIP(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, []byte("2001:db8::1\torg-root/ls-bar/ether-eth0\t\n"))
+	defer cleanup()
+
+	_, network, err := net.ParseCIDR("2001:db8::/64")
+	utils.FailOnError(t, err)
+	pool := &Pool{Network: network}
+
+	ip := net.ParseIP("2001:db8::225:b5ff:fe00:9f")
+	err = ClaimIP(inventoryFile, pool, ip, "org-root/ls-foo/ether-eth0")
+	utils.FailOnError(t, err)
+
+	// Claiming it again for the same owner is fine.
+	err = ClaimIP(inventoryFile, pool, ip, "org-root/ls-foo/ether-eth0")
+	utils.FailOnError(t, err)
+
+	inventory, err := Inventory(inventoryFile)
+	utils.FailOnError(t, err)
+
+	if len(inventory) != 2 || !inventory[1].Equal(ip) {
+		t.Errorf("[2001:db8::1 %s] expected; got %v", ip, inventory)
+	}
+
+	err = ClaimIP(inventoryFile, pool, net.ParseIP("2001:db8::1"), "org-root/ls-foo/ether-eth0")
+	if err == nil {
+		t.Errorf("error expected when claiming an IP allocated to someone else; got nil")
+	}
+
+	err = ClaimIP(inventoryFile, pool, net.ParseIP("2001:db9::1"), "org-root/ls-foo/ether-eth0")
+	if err == nil {
+		t.Errorf("error expected when claiming an IP outside of the network; got nil")
+	}
+
+	// Sequential allocations skip claimed IPs.
+	next, err := GenerateIP(inventoryFile, "2001:db8::/64")
+	utils.FailOnError(t, err)
+
+	if expected := "2001:db8::2"; next.String() != expected {
+		t.Errorf("%s expected; got %s", expected, next)
+	}
+}
+
+func TestClaimIPOutsideOfPool(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, nil)
+	defer cleanup()
+
+	_, network, err := net.ParseCIDR("10.0.1.0/24")
+	utils.FailOnError(t, err)
+
+	_, excluded, err := net.ParseCIDR("10.0.1.64/26")
+	utils.FailOnError(t, err)
+
+	pool := &Pool{
+		Name:     "eth0",
+		Network:  network,
+		Gateway:  net.ParseIP("10.0.1.1"),
+		Start:    net.ParseIP("10.0.1.10"),
+		End:      net.ParseIP("10.0.1.200"),
+		Reserved: []net.IP{net.ParseIP("10.0.1.20")},
+		Excluded: []*net.IPNet{excluded},
+	}
+
+	tests := map[string]string{
+		"10.0.1.0":   "network address",
+		"10.0.1.255": "broadcast address",
+		"10.0.1.1":   "gateway",
+		"10.0.1.5":   "IP before the range",
+		"10.0.1.201": "IP after the range",
+		"10.0.1.20":  "reserved IP",
+		"10.0.1.100": "excluded IP",
+		"10.0.2.10":  "IP outside of the network",
+	}
+
+	for ip, what := range tests {
+		if err := ClaimIP(inventoryFile, pool, net.ParseIP(ip), "org-root/ls-foo/ether-eth0"); err == nil {
+			t.Errorf("error expected when claiming the %s %s; got nil", what, ip)
+		}
+	}
+
+	inventory, err := Inventory(inventoryFile)
+	utils.FailOnError(t, err)
+
+	if len(inventory) != 0 {
+		t.Errorf("empty inventory expected; got %v", inventory)
+	}
+
+	err = ClaimIP(inventoryFile, pool, net.ParseIP("10.0.1.10"), "org-root/ls-foo/ether-eth0")
+	utils.FailOnError(t, err)
+}
+
+func TestClaimIPWithoutOwner(t *testing.T) {
+	inventoryFile, cleanup := tempInventory(t, []byte("10.0.1.1\n"))
+	defer cleanup()
+
+	_, network, err := net.ParseCIDR("10.0.1.0/24")
+	utils.FailOnError(t, err)
+
+	err = ClaimIP(inventoryFile, &Pool{Network: network}, net.ParseIP("10.0.1.1"), "org-root/ls-foo/ether-eth0")
+	utils.FailOnError(t, err)
+
+	allocations, err := Allocations(inventoryFile)
+	utils.FailOnError(t, err)
+
+	if len(allocations) != 1 || allocations[0].Owner != "org-root/ls-foo/ether-eth0" {
+		t.Errorf("10.0.1.1 expected to be owned by org-root/ls-foo/ether-eth0; got %v", allocations)
+	}
+}
@@ -0,0 +1,180 @@
+package ipman
+
+// Report lists how the allocations recorded for a pool drift from what the
+// live owners (e.g. the vNICs of the service profiles in UCS) expect.
+type Report struct {
+	Pool string
+
+	// Allocated to owners which are not live anymore.
+	Orphaned []Allocation
+
+	// Recorded without an owner, by older versions, and not expected by
+	// any live owner. They are reported but never repaired.
+	Unowned []Allocation
+
+	// Recorded more than once. The allocation which is kept, preferably
+	// the one of a live owner, is not listed. They are only repaired when
+	// the IP is kept for a live owner.
+	Duplicated []Allocation
+
+	// Expected by live owners but not recorded for them. The IP is nil
+	// when the owner does not hold any IP and which one it expects is
+	// unknown.
+	Missing []Allocation
+}
+
+// Determines whether the allocations of the pool match the live owners.
+func (r *Report) Clean() bool {
+	return len(r.Orphaned)+len(r.Unowned)+len(r.Duplicated)+len(r.Missing) == 0
+}
+
+// Compares the allocations of the pool against the live ones and reports
+// the orphaned, unowned, duplicated and missing allocations. Live
+// allocations may have a nil IP when only their owner is known.
+// Only the allocations whose owner satisfies owns (all of them if owns is
+// nil) are compared, along with those without an owner: the inventory may
+// be shared with other kinds of owners, which the live ones say nothing
+// about.
+func Reconcile(allocator Allocator, pool *Pool, live []Allocation, owns func(owner string) bool) (*Report, error) {
+	report, _, _, err := reconcile(allocator, pool, live, owns)
+	return report, err
+}
+
+// Reconciles the allocations of the pool against the live ones (see
+// Reconcile) and repairs them: orphaned IPs are released, duplicated ones
+// held by a live owner are recorded once for it, and missing ones whose IP
+// is known are claimed. IPs also allocated to owners which do not satisfy
+// owns are left alone. Returns the report of what was found before
+// repairing. Repairs are not atomic; a failure leaves them partially
+// applied.
+func Repair(allocator Allocator, pool *Pool, live []Allocation, owns func(owner string) bool) (*Report, error) {
+	report, owners, foreign, err := reconcile(allocator, pool, live, owns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Releasing an IP drops all of its entries, so duplicates are only
+	// repaired when the kept one can be claimed again for its live owner,
+	// and no IP held by a foreign owner is released.
+	released := make(map[string]bool)
+	for _, a := range report.Duplicated {
+		ip := a.IP.String()
+		owner, ok := owners[ip]
+		if !ok || released[ip] || foreign[ip] {
+			continue
+		}
+		released[ip] = true
+
+		if err := allocator.Release(pool, a.IP); err != nil {
+			return report, err
+		}
+
+		if err := allocator.Claim(pool, a.IP, owner); err != nil {
+			return report, err
+		}
+	}
+
+	for _, a := range report.Orphaned {
+		if released[a.IP.String()] || foreign[a.IP.String()] {
+			continue
+		}
+
+		if err := allocator.Release(pool, a.IP); err != nil {
+			return report, err
+		}
+	}
+
+	for _, a := range report.Missing {
+		if a.IP == nil {
+			continue
+		}
+
+		if err := allocator.Claim(pool, a.IP, a.Owner); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// Builds the report of the pool along with the live owner each recorded IP
+// is kept for and the IPs allocated to owners which do not satisfy owns.
+func reconcile(allocator Allocator, pool *Pool, live []Allocation, owns func(owner string) bool) (*Report, map[string]string, map[string]bool, error) {
+	listed, err := allocator.List(pool)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	allocations := make([]Allocation, 0, len(listed))
+	foreign := make(map[string]bool)
+	for _, a := range listed {
+		if a.Owner != "" && owns != nil && !owns(a.Owner) {
+			foreign[a.IP.String()] = true
+			continue
+		}
+		allocations = append(allocations, a)
+	}
+
+	liveOwners := make(map[string]bool, len(live))
+	expected := make(map[string]bool, len(live))
+	for _, a := range live {
+		liveOwners[a.Owner] = true
+		if a.IP != nil {
+			expected[a.IP.String()] = true
+		}
+	}
+
+	var order []string
+	byIP := make(map[string][]Allocation, len(allocations))
+	for _, a := range allocations {
+		ip := a.IP.String()
+		if _, ok := byIP[ip]; !ok {
+			order = append(order, ip)
+		}
+		byIP[ip] = append(byIP[ip], a)
+	}
+
+	report := &Report{Pool: pool.Name}
+	owners := make(map[string]string)
+	held := make(map[string]bool)
+	for _, ip := range order {
+		entries := byIP[ip]
+
+		kept := 0
+		for i, a := range entries {
+			if liveOwners[a.Owner] {
+				kept = i
+				break
+			}
+		}
+
+		for i, a := range entries {
+			if i != kept {
+				report.Duplicated = append(report.Duplicated, a)
+			}
+		}
+
+		a := entries[kept]
+		switch {
+		case liveOwners[a.Owner]:
+			owners[ip] = a.Owner
+			held[a.Owner] = true
+			held[a.Owner+"\t"+ip] = true
+		case a.Owner == "":
+			// Claiming the IP for the live owner expecting it adopts it.
+			if !expected[ip] {
+				report.Unowned = append(report.Unowned, a)
+			}
+		default:
+			report.Orphaned = append(report.Orphaned, a)
+		}
+	}
+
+	for _, a := range live {
+		if a.IP != nil && !held[a.Owner+"\t"+a.IP.String()] {
+			report.Missing = append(report.Missing, a)
+		} else if a.IP == nil && !held[a.Owner] {
+			report.Missing = append(report.Missing, a)
+		}
+	}
+	return report, owners, foreign, nil
+}
@@ -0,0 +1,145 @@
+package ipman
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	utils "github.com/ContainerSolutions/go-utils"
+)
+
+// Inventory of the reconcile tests: foo and bar are live, baz is gone,
+// 10.0.1.4 was recorded twice and 10.0.1.5 by an older version.
+const reconcileInventory = "10.0.1.1\torg-root/ls-foo/ether-eth0\t\n" +
+	"10.0.1.2\torg-root/ls-baz/ether-eth0\t\n" +
+	"10.0.1.4\torg-root/ls-baz/ether-eth0\t\n" +
+	"10.0.1.4\torg-root/ls-bar/ether-eth0\t\n" +
+	"10.0.1.5\n"
+
+func reconcileLive() []Allocation {
+	return []Allocation{
+		{IP: net.ParseIP("10.0.1.1"), Owner: "org-root/ls-foo/ether-eth0"},
+		{Owner: "org-root/ls-bar/ether-eth0"},
+		{IP: net.ParseIP("10.0.1.9"), Owner: "org-root/ls-qux/ether-eth0"},
+		{Owner: "org-root/ls-quux/ether-eth0"},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	inventoryFile := "testdata/inventory-eth0"
+	resetInventory(t, inventoryFile, []byte(reconcileInventory))
+	defer os.Remove(inventoryFile)
+	defer os.Remove(inventoryFile + ".lock")
+
+	pool := &Pool{Name: "eth0", Network: mustParseCIDR(t, "10.0.1.0/24")}
+	report, err := Reconcile(NewFileAllocator("testdata"), pool, reconcileLive(), nil)
+	utils.FailOnError(t, err)
+
+	if report.Clean() {
+		t.Fatalf("drift expected; got a clean report")
+	}
+
+	expectAllocations(t, "orphaned", report.Orphaned, "10.0.1.2")
+	expectAllocations(t, "unowned", report.Unowned, "10.0.1.5")
+	expectAllocations(t, "duplicated", report.Duplicated, "10.0.1.4")
+	expectAllocations(t, "missing", report.Missing, "10.0.1.9", "<nil>")
+
+	if owner := "org-root/ls-baz/ether-eth0"; report.Duplicated[0].Owner != owner {
+		t.Errorf("%s expected; got %s", owner, report.Duplicated[0].Owner)
+	}
+
+	if owner := "org-root/ls-quux/ether-eth0"; report.Missing[1].Owner != owner {
+		t.Errorf("%s expected; got %s", owner, report.Missing[1].Owner)
+	}
+}
+
+func TestReconcileUnownedExpected(t *testing.T) {
+	inventoryFile := "testdata/inventory-eth0"
+	resetInventory(t, inventoryFile, []byte("10.0.1.5\n"))
+	defer os.Remove(inventoryFile)
+	defer os.Remove(inventoryFile + ".lock")
+
+	live := []Allocation{{IP: net.ParseIP("10.0.1.5"), Owner: "org-root/ls-foo/ether-eth0"}}
+	pool := &Pool{Name: "eth0", Network: mustParseCIDR(t, "10.0.1.0/24")}
+	report, err := Reconcile(NewFileAllocator("testdata"), pool, live, nil)
+	utils.FailOnError(t, err)
+
+	expectAllocations(t, "unowned", report.Unowned)
+	expectAllocations(t, "missing", report.Missing, "10.0.1.5")
+}
+
+func TestRepair(t *testing.T) {
+	// 10.0.1.6 is kept without an owner, so its duplicate can't be repaired.
+	inventoryFile := "testdata/inventory-eth0"
+	resetInventory(t, inventoryFile, []byte(reconcileInventory+"10.0.1.6\n10.0.1.6\torg-root/ls-baz/ether-eth0\t\n"))
+	defer os.Remove(inventoryFile)
+	defer os.Remove(inventoryFile + ".lock")
+
+	allocator := NewFileAllocator("testdata")
+	pool := &Pool{Name: "eth0", Network: mustParseCIDR(t, "10.0.1.0/24")}
+	_, err := Repair(allocator, pool, reconcileLive(), nil)
+	utils.FailOnError(t, err)
+
+	allocations, err := allocator.List(pool)
+	utils.FailOnError(t, err)
+
+	expectAllocations(t, "allocations", allocations, "10.0.1.1", "10.0.1.5", "10.0.1.6", "10.0.1.6", "10.0.1.4", "10.0.1.9")
+
+	if owner := "org-root/ls-bar/ether-eth0"; allocations[4].Owner != owner {
+		t.Errorf("%s expected; got %s", owner, allocations[4].Owner)
+	}
+
+	if allocations[2].Owner != "" {
+		t.Errorf("blank owner expected for 10.0.1.6; got %s", allocations[2].Owner)
+	}
+
+	report, err := Reconcile(allocator, pool, reconcileLive(), nil)
+	utils.FailOnError(t, err)
+
+	// Only what cannot be repaired is left.
+	expectAllocations(t, "orphaned", report.Orphaned)
+	expectAllocations(t, "unowned", report.Unowned, "10.0.1.5", "10.0.1.6")
+	expectAllocations(t, "duplicated", report.Duplicated, "10.0.1.6")
+	expectAllocations(t, "missing", report.Missing, "<nil>")
+}
+
+func TestRepairLeavesForeignOwnersAlone(t *testing.T) {
+	// www is not a vNIC, and 10.0.1.2 is held by it as well as by the
+	// vNIC of a profile which is gone.
+	inventoryFile := "testdata/inventory-eth0"
+	resetInventory(t, inventoryFile, []byte(reconcileInventory+"10.0.1.2\twww\t\n10.0.1.7\twww\t\n"))
+	defer os.Remove(inventoryFile)
+	defer os.Remove(inventoryFile + ".lock")
+
+	vnics := func(owner string) bool { return owner != "www" }
+	allocator := NewFileAllocator("testdata")
+	pool := &Pool{Name: "eth0", Network: mustParseCIDR(t, "10.0.1.0/24")}
+	report, err := Repair(allocator, pool, reconcileLive(), vnics)
+	utils.FailOnError(t, err)
+
+	expectAllocations(t, "orphaned", report.Orphaned, "10.0.1.2")
+
+	allocations, err := allocator.List(pool)
+	utils.FailOnError(t, err)
+
+	var kept []Allocation
+	for _, a := range allocations {
+		if a.Owner == "www" {
+			kept = append(kept, a)
+		}
+	}
+	expectAllocations(t, "www", kept, "10.0.1.2", "10.0.1.7")
+}
+
+func expectAllocations(t *testing.T, kind string, allocations []Allocation, ips ...string) {
+	if len(allocations) != len(ips) {
+		t.Errorf("%d %s allocations expected; got %v", len(ips), kind, allocations)
+		return
+	}
+
+	for i, a := range allocations {
+		if a.IP.String() != ips[i] {
+			t.Errorf("%s expected; got %s", ips[i], a.IP)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package ipman
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+)
+
+// ClassResolver is the part of ucsclient.UCSClient the UCS allocator needs.
+type ClassResolver interface {
+	ResolveClass(classId string, filter ucsclient.Filter, hierarchical bool) ([]*ucsclient.ManagedObject, error)
+}
+
+// UCSAllocator allocates IPs from the blocks (ippoolBlock) of the UCS IP
+// pool named after each pool, e.g. org-root/ip-pool-eth0, never handing
+// out the addresses UCS assigned to its own consumers (ippoolPooled).
+// UCS has no API to assign an address to anything but its own objects, so
+// the allocations made through it are recorded by Records and UCS could
+// still hand them out itself. That is why only the blocks listed in Blocks
+// (by DN), which are kept from UCS consumers, are allocated from. UCS IP
+// pools are IPv4 only, so IPv6 pools are left to Records alone.
+type UCSAllocator struct {
+	Client  ClassResolver
+	Org     string
+	Blocks  []string
+	Records Allocator
+}
+
+// A block of addresses of a UCS IP pool.
+type ucsBlock struct {
+	network *net.IPNet
+	from    net.IP
+	to      net.IP
+	gateway net.IP
+}
+
+// Returns a UCSAllocator allocating from the given blocks of the IP pools
+// of org and recording its allocations in inventory files in dir.
+func NewUCSAllocator(client ClassResolver, org, dir string, blocks []string) *UCSAllocator {
+	return &UCSAllocator{
+		Client:  client,
+		Org:     org,
+		Blocks:  blocks,
+		Records: NewFileAllocator(dir),
+	}
+}
+
+// Returns the DN of the UCS IP pool the pool is backed by.
+func (a *UCSAllocator) PoolDn(pool *Pool) string {
+	return a.Org + "/ip-pool-" + pool.Name
+}
+
+func (a *UCSAllocator) Allocate(pool *Pool, owner string) (net.IP, error) {
+	if isIPv6(pool) {
+		return a.Records.Allocate(pool, owner)
+	}
+
+	blocks, assigned, err := a.resolve(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, block := range blocks {
+		p, ok := block.narrow(pool, assigned)
+		if !ok {
+			continue
+		}
+
+		ip, err := a.Records.Allocate(p, owner)
+		if IsPoolExhausted(err) {
+			continue
+		}
+		return ip, err
+	}
+
+	network := pool.Network
+	if network == nil {
+		network = blocks[0].network
+	}
+	return nil, &PoolExhaustedError{Network: network}
+}
+
+func (a *UCSAllocator) Claim(pool *Pool, ip net.IP, owner string) error {
+	if isIPv6(pool) {
+		return a.Records.Claim(pool, ip, owner)
+	}
+
+	blocks, assigned, err := a.resolve(pool)
+	if err != nil {
+		return err
+	}
+
+	if assignedTo, ok := assigned[ip.String()]; ok {
+		return fmt.Errorf("%s is already assigned to %q by UCS", ip, assignedTo)
+	}
+
+	for _, block := range blocks {
+		if !block.contains(ip) {
+			continue
+		}
+
+		if p, ok := block.narrow(pool, assigned); ok {
+			return a.Records.Claim(p, ip, owner)
+		}
+	}
+	return fmt.Errorf("%s does not belong to any listed block of UCS IP pool %s", ip, a.PoolDn(pool))
+}
+
+func (a *UCSAllocator) Release(pool *Pool, ip net.IP) error {
+	return a.Records.Release(pool, ip)
+}
+
+func (a *UCSAllocator) Lookup(pool *Pool, ip net.IP) (*Allocation, error) {
+	return a.Records.Lookup(pool, ip)
+}
+
+func (a *UCSAllocator) List(pool *Pool) ([]Allocation, error) {
+	return a.Records.List(pool)
+}
+
+// Determines whether pool is an IPv6 one, which no UCS IP pool backs.
+func isIPv6(pool *Pool) bool {
+	return pool.Network != nil && pool.Network.IP.To4() == nil
+}
+
+// Fetches the blocks of the UCS IP pool backing pool along with the
+// addresses of those blocks UCS assigned, mapped to whom they are assigned.
+// The gateways of the blocks count as assigned. Both queries are narrowed
+// down to the objects of the pool, whose DN is matched literally.
+func (a *UCSAllocator) resolve(pool *Pool) ([]ucsBlock, map[string]string, error) {
+	dn := a.PoolDn(pool)
+	within := "^" + regexp.QuoteMeta(dn) + "/"
+	objects, err := a.Client.ResolveClass("ippoolBlock", ucsclient.Wcard("ippoolBlock", "dn", within), false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(objects) == 0 {
+		return nil, nil, fmt.Errorf("UCS IP pool %s has no blocks", dn)
+	}
+
+	listed := make(map[string]bool, len(a.Blocks))
+	for _, block := range a.Blocks {
+		listed[block] = true
+	}
+
+	kept := make([]*ucsclient.ManagedObject, 0, len(objects))
+	blocks := make([]ucsBlock, 0, len(objects))
+	for _, mo := range objects {
+		if !listed[mo.Dn()] {
+			continue
+		}
+
+		block, err := newUCSBlock(mo)
+		if err != nil {
+			return nil, nil, err
+		}
+		kept, blocks = append(kept, mo), append(blocks, block)
+	}
+
+	if len(blocks) == 0 {
+		return nil, nil, fmt.Errorf("None of the blocks of UCS IP pool %s is listed in the blocks of the ucs backend", dn)
+	}
+
+	addrs, err := a.Client.ResolveClass("ippoolPooled", ucsclient.And(
+		ucsclient.Wcard("ippoolPooled", "dn", within),
+		ucsclient.Eq("ippoolPooled", "assigned", "yes"),
+	), false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assigned := make(map[string]string)
+	for i, block := range blocks {
+		if block.gateway != nil {
+			assigned[block.gateway.String()] = "gateway of " + kept[i].Dn()
+		}
+	}
+
+	for _, mo := range addrs {
+		ip := net.ParseIP(mo.Get("id"))
+		if ip == nil {
+			continue
+		}
+
+		for _, block := range blocks {
+			if block.contains(ip) {
+				assigned[ip.String()] = mo.Get("assignedToDn")
+				break
+			}
+		}
+	}
+	return blocks, assigned, nil
+}
+
+func newUCSBlock(mo *ucsclient.ManagedObject) (ucsBlock, error) {
+	block := ucsBlock{
+		from:    net.ParseIP(mo.Get("from")).To4(),
+		to:      net.ParseIP(mo.Get("to")).To4(),
+		gateway: net.ParseIP(mo.Get("defGw")),
+	}
+
+	mask := net.IPMask(net.ParseIP(mo.Get("subnet")).To4())
+	if block.from == nil || block.to == nil || mask == nil {
+		return block, fmt.Errorf("Invalid UCS IP pool block %s: from %q, to %q, subnet %q", mo.Dn(), mo.Get("from"), mo.Get("to"), mo.Get("subnet"))
+	}
+	block.network = &net.IPNet{IP: block.from.Mask(mask), Mask: mask}
+	return block, nil
+}
+
+func (b ucsBlock) contains(ip net.IP) bool {
+	ip = ip.To4()
+	return ip != nil && bytes.Compare(ip, b.from) >= 0 && bytes.Compare(ip, b.to) <= 0
+}
+
+// Returns a copy of pool narrowed down to the block, never handing out the
+// addresses UCS assigned. Returns false if the
+// block lies outside of the pool's network or range.
+func (b ucsBlock) narrow(pool *Pool, assigned map[string]string) (*Pool, bool) {
+	p := *pool
+	if p.Network == nil {
+		p.Network = b.network
+	}
+
+	if !p.Network.Contains(b.from) || !p.Network.Contains(b.to) {
+		return nil, false
+	}
+
+	p.Start, p.End = b.from, b.to
+	if start := pool.Start.To4(); start != nil && bytes.Compare(start, b.from) > 0 {
+		p.Start = start
+	}
+	if end := pool.End.To4(); end != nil && bytes.Compare(end, b.to) < 0 {
+		p.End = end
+	}
+
+	if bytes.Compare(p.Start, p.End) > 0 {
+		return nil, false
+	}
+
+	p.Reserved = append([]net.IP{}, pool.Reserved...)
+	for ip := range assigned {
+		p.Reserved = append(p.Reserved, net.ParseIP(ip))
+	}
+	return &p, true
+}
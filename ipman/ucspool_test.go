@@ -0,0 +1,223 @@
+package ipman
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+	utils "github.com/ContainerSolutions/go-utils"
+)
+
+// Answers class queries with canned managed objects.
+type stubResolver map[string][]*ucsclient.ManagedObject
+
+func (r stubResolver) ResolveClass(classId string, filter ucsclient.Filter, hierarchical bool) ([]*ucsclient.ManagedObject, error) {
+	return r[classId], nil
+}
+
+// Records the filter of each class query before answering it.
+type recordingResolver struct {
+	stubResolver
+	filters map[string]ucsclient.Filter
+}
+
+func (r *recordingResolver) ResolveClass(classId string, filter ucsclient.Filter, hierarchical bool) ([]*ucsclient.ManagedObject, error) {
+	r.filters[classId] = filter
+	return r.stubResolver.ResolveClass(classId, filter, hierarchical)
+}
+
+// The DNs of both blocks of the stub pool.
+var stubUCSBlocks = []string{
+	"org-root/ip-pool-eth0/block-10.0.1.1-10.0.1.4",
+	"org-root/ip-pool-eth0/block-10.0.1.10-10.0.1.11",
+}
+
+func newStubUCSPool() stubResolver {
+	return stubResolver{
+		"ippoolBlock": {
+			ucsclient.NewManagedObject("ippoolBlock", "org-root/ip-pool-eth0/block-10.0.1.1-10.0.1.4").
+				Set("from", "10.0.1.1").
+				Set("to", "10.0.1.4").
+				Set("subnet", "255.255.255.0").
+				Set("defGw", "10.0.1.254"),
+			ucsclient.NewManagedObject("ippoolBlock", "org-root/ip-pool-eth0/block-10.0.1.10-10.0.1.11").
+				Set("from", "10.0.1.10").
+				Set("to", "10.0.1.11").
+				Set("subnet", "255.255.255.0").
+				Set("defGw", "10.0.1.1"),
+		},
+		"ippoolPooled": {
+			ucsclient.NewManagedObject("ippoolPooled", "org-root/ip-pool-eth0/ip-10.0.1.2").
+				Set("id", "10.0.1.2").
+				Set("assigned", "yes").
+				Set("assignedToDn", "org-root/ls-foo/ipv4-pooled-addr"),
+			ucsclient.NewManagedObject("ippoolPooled", "org-root/ip-pool-eth0/ip-10.0.2.1").
+				Set("id", "10.0.2.1").
+				Set("assigned", "yes").
+				Set("assignedToDn", "org-root/ls-bar/ipv4-pooled-addr"),
+		},
+	}
+}
+
+func TestUCSAllocator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+	defer os.RemoveAll(dir)
+
+	allocator := NewUCSAllocator(newStubUCSPool(), "org-root", dir, stubUCSBlocks)
+	pool := &Pool{Name: "eth0"}
+
+	if dn := allocator.PoolDn(pool); dn != "org-root/ip-pool-eth0" {
+		t.Errorf("%s expected; got %s", "org-root/ip-pool-eth0", dn)
+	}
+
+	// 10.0.1.1 is the gateway of the second block and 10.0.1.2 is assigned
+	// by UCS, so the second block is used once 10.0.1.3-4 are allocated.
+	var ips []string
+	for i := 0; i < 3; i++ {
+		ip, err := allocator.Allocate(pool, "www")
+		utils.FailOnError(t, err)
+		ips = append(ips, ip.String())
+	}
+
+	expected := []string{"10.0.1.3", "10.0.1.4", "10.0.1.10"}
+	for i := range expected {
+		if ips[i] != expected[i] {
+			t.Errorf("%s expected; got %s", expected[i], ips[i])
+		}
+	}
+
+	allocation, err := allocator.Lookup(pool, net.ParseIP("10.0.1.10"))
+	utils.FailOnError(t, err)
+
+	if allocation == nil || allocation.Owner != "www" {
+		t.Errorf("10.0.1.10 expected to be allocated to www; got %v", allocation)
+	}
+
+	_, err = allocator.Allocate(pool, "www")
+	utils.FailOnError(t, err)
+
+	if _, err := allocator.Allocate(pool, "www"); !IsPoolExhausted(err) {
+		t.Errorf("pool exhausted error expected; got %v", err)
+	}
+}
+
+func TestUCSAllocatorClaim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+	defer os.RemoveAll(dir)
+
+	allocator := NewUCSAllocator(newStubUCSPool(), "org-root", dir, stubUCSBlocks)
+	pool := &Pool{Name: "eth0"}
+
+	utils.FailOnError(t, allocator.Claim(pool, net.ParseIP("10.0.1.11"), "www"))
+
+	for _, ip := range []string{"10.0.1.1", "10.0.1.2"} {
+		if err := allocator.Claim(pool, net.ParseIP(ip), "www"); err == nil {
+			t.Errorf("error expected for %s, assigned by UCS; got nil", ip)
+		}
+	}
+
+	if err := allocator.Claim(pool, net.ParseIP("10.0.1.20"), "www"); err == nil {
+		t.Errorf("error expected for an address outside of the blocks; got nil")
+	}
+}
+
+func TestUCSAllocatorListedBlocksOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+	defer os.RemoveAll(dir)
+
+	// UCS consumers of the pool may be handed the addresses of the first
+	// block, so it is not listed.
+	allocator := NewUCSAllocator(newStubUCSPool(), "org-root", dir, stubUCSBlocks[1:])
+	pool := &Pool{Name: "eth0"}
+
+	ip, err := allocator.Allocate(pool, "www")
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.1.10"; ip.String() != expected {
+		t.Errorf("%s expected; got %s", expected, ip)
+	}
+
+	if err := allocator.Claim(pool, net.ParseIP("10.0.1.3"), "www"); err == nil {
+		t.Errorf("error expected for an address of a block which is not listed; got nil")
+	}
+
+	allocator = NewUCSAllocator(newStubUCSPool(), "org-root", dir, nil)
+	if _, err := allocator.Allocate(pool, "www"); err == nil {
+		t.Errorf("error expected when no block is listed; got nil")
+	}
+}
+
+func TestUCSAllocatorWithinNetwork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+	defer os.RemoveAll(dir)
+
+	allocator := NewUCSAllocator(newStubUCSPool(), "org-root", dir, stubUCSBlocks)
+	pool := &Pool{
+		Name:    "eth0",
+		Network: mustParseCIDR(t, "10.0.1.8/29"),
+	}
+
+	ip, err := allocator.Allocate(pool, "www")
+	utils.FailOnError(t, err)
+
+	if expected := "10.0.1.10"; ip.String() != expected {
+		t.Errorf("%s expected; got %s", expected, ip)
+	}
+}
+
+func TestUCSAllocatorIPv6(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+	defer os.RemoveAll(dir)
+
+	// UCS IP pools have no IPv6 blocks, so IPv6 pools are only recorded.
+	allocator := NewUCSAllocator(stubResolver{}, "org-root", dir, nil)
+	pool := &Pool{Name: "eth0", Network: mustParseCIDR(t, "2001:db8::/64")}
+
+	ip, err := allocator.Allocate(pool, "www")
+	utils.FailOnError(t, err)
+
+	if expected := "2001:db8::1"; ip.String() != expected {
+		t.Errorf("%s expected; got %s", expected, ip)
+	}
+
+	utils.FailOnError(t, allocator.Claim(pool, net.ParseIP("2001:db8::225:b5ff:fe00:9f"), "www"))
+}
+
+func TestUCSAllocatorWithoutBlocks(t *testing.T) {
+	allocator := NewUCSAllocator(stubResolver{}, "org-root", "testdata", stubUCSBlocks)
+	if _, err := allocator.Allocate(&Pool{Name: "eth0"}, "www"); err == nil {
+		t.Errorf("error expected for a pool without blocks; got nil")
+	}
+}
+
+func TestUCSAllocatorQueriesPoolOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipman")
+	utils.FailOnError(t, err)
+	defer os.RemoveAll(dir)
+
+	resolver := &recordingResolver{newStubUCSPool(), make(map[string]ucsclient.Filter)}
+	allocator := NewUCSAllocator(resolver, "org-root", dir, stubUCSBlocks)
+
+	// The dot must not match any character, e.g. ip-pool-eth0x1.
+	_, err = allocator.Allocate(&Pool{Name: "eth0.1"}, "www")
+	utils.FailOnError(t, err)
+
+	expected := map[string]ucsclient.Filter{
+		"ippoolBlock": ucsclient.Wcard("ippoolBlock", "dn", `^org-root/ip-pool-eth0\.1/`),
+		"ippoolPooled": ucsclient.And(
+			ucsclient.Wcard("ippoolPooled", "dn", `^org-root/ip-pool-eth0\.1/`),
+			ucsclient.Eq("ippoolPooled", "assigned", "yes"),
+		),
+	}
+	if !reflect.DeepEqual(resolver.filters, expected) {
+		t.Errorf("%v expected; got %v", expected, resolver.filters)
+	}
+}
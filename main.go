@@ -11,4 +11,7 @@ func main() {
 			return Provider()
 		},
 	})
+
+	// Serve only returns once Terraform is done with the plugin.
+	logoutClients()
 }
@@ -1,11 +1,48 @@
 package main
 
 import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/CiscoUcs/UCS-Terraform/ipman"
 	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
 )
 
+// Clients configured during the lifetime of the plugin. Each of them holds a
+// UCS session which is closed once Terraform shuts the plugin down.
+var (
+	clients      = make([]*ucsclient.UCSClient, 0, 1)
+	clientsMutex = sync.Mutex{}
+)
+
+// Everything the resources of the provider need to do their job. It is
+// handed to them as their `meta` argument.
+type providerMeta struct {
+	client    *ucsclient.UCSClient
+	allocator ipman.Allocator
+	pools     map[string]*ipman.Pool
+}
+
+// IPAM backends which can be chosen in the provider's `ipam` block, keyed
+// by name. Each of them builds an allocator from the block's settings.
+var ipamBackends = map[string]func(config map[string]interface{}, client *ucsclient.UCSClient) (ipman.Allocator, error){
+	"file": func(config map[string]interface{}, client *ucsclient.UCSClient) (ipman.Allocator, error) {
+		return ipman.NewFileAllocator(config["directory"].(string)), nil
+	},
+	"ucs": func(config map[string]interface{}, client *ucsclient.UCSClient) (ipman.Allocator, error) {
+		blocks := expandStrings(config["blocks"])
+		if len(blocks) == 0 {
+			return nil, fmt.Errorf("The ucs IPAM backend requires the blocks it may allocate from")
+		}
+		return ipman.NewUCSAllocator(client, config["org"].(string), config["directory"].(string), blocks), nil
+	},
+}
+
 func Provider() terraform.ResourceProvider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
@@ -47,10 +84,118 @@ func Provider() terraform.ResourceProvider {
 				Default:     "",
 				Description: "The log filename",
 			},
+
+			"max_concurrent_requests": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     8,
+				Description: "Maximum number of concurrent requests sent to UCS Manager. 0 means no limit.",
+			},
+
+			"ipam": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Where the IPs of the vNICs are allocated from.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"backend": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "file",
+							ValidateFunc: validateIPAMBackend,
+							Description:  "The IPAM backend.",
+						},
+						"directory": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+							Description: "Directory holding the inventory files. Defaults to the working directory.",
+						},
+						"org": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "org-root",
+							Description: "Organization holding the IP pools of the ucs backend.",
+						},
+						"blocks": &schema.Schema{
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "DNs of the UCS IP pool blocks the ucs backend allocates from, kept for Terraform.",
+						},
+					},
+				},
+			},
+
+			"ip_pool": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Named pools ucs_ip_allocation resources allocate IPs from.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the pool.",
+						},
+						"cidr": &schema.Schema{
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The network IPs are allocated from.",
+						},
+						"gateway": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validateIP,
+							Description:  "The gateway of the network. It is never allocated.",
+						},
+						"dns_servers": &schema.Schema{
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: validateIP},
+							Description: "The DNS servers of the network.",
+						},
+						"domain": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The DNS domain of the network.",
+						},
+						"exclude": &schema.Schema{
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "IPs or CIDRs which must never be allocated",
+						},
+						"range_start": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validateIP,
+							Description:  "First IP of the network which may be allocated",
+						},
+						"range_end": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validateIP,
+							Description:  "Last IP of the network which may be allocated",
+						},
+					},
+				},
+			},
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
 			"ucs_service_profile": resourceUcsServiceProfile(),
+			"ucs_ip_allocation":   resourceUcsIPAllocation(),
+			"ucs_org":             resourceUcsOrg(),
+			"ucs_vlan":            resourceUcsVlan(),
+			"ucs_vlan_group":      resourceUcsVlanGroup(),
+			"ucs_mac_pool":        resourceUcsMacPool(),
+			"ucs_vsan":            resourceUcsVsan(),
+		},
+
+		DataSourcesMap: map[string]*schema.Resource{
+			"ucs_ip_reconciliation": dataSourceUcsIPReconciliation(),
 		},
 
 		ConfigureFunc: providerConfigure,
@@ -66,7 +211,111 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		TslInsecureSkipVerify: d.Get("tslinsecureskipverify").(bool),
 		LogFilename:           d.Get("log_filename").(string),
 		LogLevel:              d.Get("log_level").(int),
+		MaxConcurrentRequests: d.Get("max_concurrent_requests").(int),
+	}
+
+	client := config.Client()
+
+	allocator, err := newAllocator(d.Get("ipam").([]interface{}), client)
+	if err != nil {
+		return nil, err
 	}
 
-	return config.Client(), nil
+	pools, err := expandIPPools(d.Get("ip_pool").([]interface{}))
+	if err != nil {
+		return nil, err
+	}
+
+	clientsMutex.Lock()
+	clients = append(clients, client)
+	clientsMutex.Unlock()
+
+	return &providerMeta{
+		client:    client,
+		allocator: allocator,
+		pools:     pools,
+	}, nil
+}
+
+// Builds the allocator chosen in the `ipam` block. Without the block IPs
+// are kept in inventory files in the working directory, as they always were.
+func newAllocator(ipam []interface{}, client *ucsclient.UCSClient) (ipman.Allocator, error) {
+	config := map[string]interface{}{
+		"backend":   "file",
+		"directory": "",
+		"org":       "org-root",
+	}
+	if len(ipam) > 0 && ipam[0] != nil {
+		config = ipam[0].(map[string]interface{})
+	}
+
+	backend := config["backend"].(string)
+	build, ok := ipamBackends[backend]
+	if !ok {
+		return nil, fmt.Errorf("Unknown IPAM backend %q", backend)
+	}
+	return build(config, client)
+}
+
+// Builds the pools declared in the `ip_pool` blocks, keyed by name.
+func expandIPPools(raw []interface{}) (map[string]*ipman.Pool, error) {
+	pools := make(map[string]*ipman.Pool, len(raw))
+	for _, r := range raw {
+		m := r.(map[string]interface{})
+		name := m["name"].(string)
+		if _, ok := pools[name]; ok {
+			return nil, fmt.Errorf("IP pool %s is declared more than once", name)
+		}
+
+		pool, err := vnicPool(name, m["cidr"].(string))
+		if err != nil {
+			return nil, err
+		}
+
+		pool.Gateway = net.ParseIP(stringOrEmpty(m["gateway"]))
+		pool.Domain = stringOrEmpty(m["domain"])
+		for _, server := range expandStrings(m["dns_servers"]) {
+			ip := net.ParseIP(server)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid DNS server %q of IP pool %s", server, name)
+			}
+			pool.DNSServers = append(pool.DNSServers, ip)
+		}
+		pool.Start = net.ParseIP(stringOrEmpty(m["range_start"]))
+		pool.End = net.ParseIP(stringOrEmpty(m["range_end"]))
+		pool.Reserved, pool.Excluded, err = parseExclusions(expandStrings(m["exclude"]))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := pool.Validate(); err != nil {
+			return nil, err
+		}
+		pools[name] = pool
+	}
+	return pools, nil
+}
+
+func validateIPAMBackend(v interface{}, k string) (ws []string, errors []error) {
+	backend := v.(string)
+	if _, ok := ipamBackends[backend]; !ok {
+		names := make([]string, 0, len(ipamBackends))
+		for name := range ipamBackends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		errors = append(errors, fmt.Errorf("%q must be one of %s; got %q", k, strings.Join(names, ", "), backend))
+	}
+	return
+}
+
+// Closes the UCS session of every client configured by the plugin.
+func logoutClients() {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
+	for _, client := range clients {
+		client.Logout()
+	}
+	clients = clients[:0]
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"testing"
 
+	"github.com/CiscoUcs/UCS-Terraform/ipman"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
 )
@@ -38,3 +39,146 @@ func TestproviderConfigure(t *testing.T) {
 		t.Errorf("providerConfigure() returned nil client; expected an instance of a UCSClient")
 	}
 }
+
+func TestNewAllocator(t *testing.T) {
+	allocator, err := newAllocator([]interface{}{}, nil)
+	if err != nil {
+		t.Fatalf("newAllocator() returned unexpected error %v", err)
+	}
+
+	if _, ok := allocator.(*ipman.FileAllocator); !ok {
+		t.Errorf("*ipman.FileAllocator expected; got %T", allocator)
+	}
+
+	allocator, err = newAllocator([]interface{}{
+		map[string]interface{}{
+			"backend":   "file",
+			"directory": "/var/lib/ucs",
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("newAllocator() returned unexpected error %v", err)
+	}
+
+	if dir := allocator.(*ipman.FileAllocator).Dir; dir != "/var/lib/ucs" {
+		t.Errorf("%s expected; got %s", "/var/lib/ucs", dir)
+	}
+
+	allocator, err = newAllocator([]interface{}{
+		map[string]interface{}{
+			"backend":   "ucs",
+			"directory": "/var/lib/ucs",
+			"org":       "org-root/org-a",
+			"blocks":    []interface{}{"org-root/org-a/ip-pool-eth0/block-10.0.1.100-10.0.1.199"},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("newAllocator() returned unexpected error %v", err)
+	}
+
+	if org := allocator.(*ipman.UCSAllocator).Org; org != "org-root/org-a" {
+		t.Errorf("%s expected; got %s", "org-root/org-a", org)
+	}
+
+	if blocks := allocator.(*ipman.UCSAllocator).Blocks; len(blocks) != 1 {
+		t.Errorf("1 block expected; got %v", blocks)
+	}
+
+	_, err = newAllocator([]interface{}{
+		map[string]interface{}{
+			"backend":   "ucs",
+			"directory": "",
+			"org":       "org-root",
+			"blocks":    []interface{}{},
+		},
+	}, nil)
+	if err == nil {
+		t.Errorf("error expected for the ucs backend without blocks; got nil")
+	}
+
+	_, err = newAllocator([]interface{}{
+		map[string]interface{}{
+			"backend": "carrier-pigeon",
+		},
+	}, nil)
+	if err == nil {
+		t.Errorf("error expected for an unknown backend; got nil")
+	}
+}
+
+func TestValidateIPAMBackend(t *testing.T) {
+	_, errs := validateIPAMBackend("file", "backend")
+	if len(errs) > 0 {
+		t.Errorf("no errors expected; got %v", errs)
+	}
+
+	_, errs = validateIPAMBackend("carrier-pigeon", "backend")
+	if len(errs) == 0 {
+		t.Errorf("error expected; got nil")
+	}
+}
+
+func TestExpandIPPools(t *testing.T) {
+	pools, err := expandIPPools([]interface{}{
+		map[string]interface{}{
+			"name":        "frontend",
+			"cidr":        "10.0.1.0/24",
+			"gateway":     "10.0.1.1",
+			"dns_servers": []interface{}{"10.0.0.53", "10.0.0.54"},
+			"domain":      "example.com",
+			"exclude":     []interface{}{"10.0.1.100/30"},
+			"range_start": "10.0.1.10",
+			"range_end":   "",
+		},
+	})
+	if err != nil {
+		t.Fatalf("expandIPPools() returned unexpected error %v", err)
+	}
+
+	pool, ok := pools["frontend"]
+	if !ok {
+		t.Fatalf("pool frontend expected; got %v", pools)
+	}
+
+	if expected := "10.0.1.0/24"; pool.Network.String() != expected {
+		t.Errorf("%s expected; got %s", expected, pool.Network)
+	}
+
+	if expected := "10.0.1.1"; pool.Gateway.String() != expected {
+		t.Errorf("%s expected; got %s", expected, pool.Gateway)
+	}
+
+	if len(pool.DNSServers) != 2 || pool.DNSServers[1].String() != "10.0.0.54" {
+		t.Errorf("[10.0.0.53 10.0.0.54] expected; got %v", pool.DNSServers)
+	}
+
+	if expected := "example.com"; pool.Domain != expected {
+		t.Errorf("%s expected; got %s", expected, pool.Domain)
+	}
+
+	if expected := "10.0.1.10"; pool.Start.String() != expected {
+		t.Errorf("%s expected; got %s", expected, pool.Start)
+	}
+
+	if pool.End != nil {
+		t.Errorf("nil expected; got %s", pool.End)
+	}
+
+	if len(pool.Excluded) != 1 {
+		t.Errorf("1 excluded network expected; got %d", len(pool.Excluded))
+	}
+}
+
+func TestExpandIPPoolsInvalid(t *testing.T) {
+	duplicated := map[string]interface{}{"name": "frontend", "cidr": "10.0.1.0/24"}
+	for _, raw := range [][]interface{}{
+		{duplicated, duplicated},
+		{map[string]interface{}{"name": "frontend", "cidr": "foo"}},
+		{map[string]interface{}{"name": "frontend", "cidr": "10.0.1.0/24", "gateway": "10.0.2.1"}},
+		{map[string]interface{}{"name": "frontend", "cidr": "10.0.1.0/24", "dns_servers": []interface{}{"foo"}}},
+	} {
+		if _, err := expandIPPools(raw); err == nil {
+			t.Errorf("error expected for %v; got nil", raw)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/CiscoUcs/UCS-Terraform/ipman"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// An IP allocated from one of the pools declared in the provider, so that
+// it can be referenced by other resources (DNS records, load balancers...)
+// the same way the IPs of vNICs are.
+func resourceUcsIPAllocation() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceUcsIPAllocationCreate,
+		Read:   resourceUcsIPAllocationRead,
+		Delete: resourceUcsIPAllocationDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceUcsIPAllocationImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"pool": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name of the ip_pool of the provider the IP is allocated from",
+			},
+			"owner": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Who the IP is allocated to, e.g. a hostname",
+			},
+			"ip": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validateIP,
+				Description:  "Pins the allocation to this IP instead of taking the next free one",
+			},
+			"netmask": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"prefix_length": &schema.Schema{
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"gateway": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"dns_servers": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"domain": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceUcsIPAllocationCreate(d *schema.ResourceData, meta interface{}) error {
+	allocator := meta.(*providerMeta).allocator
+	pool, err := lookupIPPool(meta.(*providerMeta).pools, d.Get("pool").(string))
+	if err != nil {
+		return err
+	}
+
+	owner := d.Get("owner").(string)
+	ip := net.ParseIP(d.Get("ip").(string))
+	if ip != nil {
+		err = allocator.Claim(pool, ip, owner)
+	} else {
+		ip, err = allocator.Allocate(pool, owner)
+	}
+	if err != nil {
+		return err
+	}
+
+	d.SetId(ipAllocationID(pool.Name, ip))
+	return resourceUcsIPAllocationRead(d, meta)
+}
+
+func resourceUcsIPAllocationRead(d *schema.ResourceData, meta interface{}) error {
+	allocator := meta.(*providerMeta).allocator
+	name, ip, err := parseIPAllocationID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	pool, err := lookupIPPool(meta.(*providerMeta).pools, name)
+	if err != nil {
+		return err
+	}
+
+	allocation, err := allocator.Lookup(pool, ip)
+	if err != nil {
+		return err
+	}
+
+	// The IP was released or handed over outside of Terraform.
+	owner := d.Get("owner").(string)
+	if allocation == nil || (owner != "" && allocation.Owner != owner) {
+		d.SetId("")
+		return nil
+	}
+
+	prefixLength, _ := pool.Network.Mask.Size()
+	d.Set("pool", pool.Name)
+	d.Set("owner", allocation.Owner)
+	d.Set("ip", ip.String())
+	d.Set("netmask", net.IP(pool.Network.Mask).String())
+	d.Set("prefix_length", prefixLength)
+	d.Set("gateway", ipOrEmpty(pool.Gateway))
+	d.Set("dns_servers", ipsToStrings(pool.DNSServers))
+	d.Set("domain", pool.Domain)
+	return nil
+}
+
+func resourceUcsIPAllocationDelete(d *schema.ResourceData, meta interface{}) error {
+	allocator := meta.(*providerMeta).allocator
+	name, ip, err := parseIPAllocationID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	pool, err := lookupIPPool(meta.(*providerMeta).pools, name)
+	if err != nil {
+		return err
+	}
+
+	err = allocator.Release(pool, ip)
+	if err != nil {
+		return err
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// Imports an existing allocation given its pool and IP, e.g.
+// `terraform import ucs_ip_allocation.x frontend/10.0.1.20`.
+func resourceUcsIPAllocationImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if _, _, err := parseIPAllocationID(d.Id()); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+func lookupIPPool(pools map[string]*ipman.Pool, name string) (*ipman.Pool, error) {
+	pool, ok := pools[name]
+	if !ok {
+		return nil, fmt.Errorf("IP pool %s is not declared in the provider", name)
+	}
+	return pool, nil
+}
+
+// Allocations are identified by their pool and IP, e.g. "frontend/10.0.1.20".
+func ipAllocationID(pool string, ip net.IP) string {
+	return pool + "/" + ip.String()
+}
+
+func parseIPAllocationID(id string) (string, net.IP, error) {
+	i := strings.LastIndex(id, "/")
+	if i <= 0 {
+		return "", nil, fmt.Errorf("Invalid IP allocation ID %q: expected <pool>/<ip>", id)
+	}
+
+	ip := net.ParseIP(id[i+1:])
+	if ip == nil {
+		return "", nil, fmt.Errorf("Invalid IP allocation ID %q: expected <pool>/<ip>", id)
+	}
+	return id[:i], ip, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/CiscoUcs/UCS-Terraform/ipman"
+)
+
+func TestIPAllocationID(t *testing.T) {
+	id := ipAllocationID("frontend", net.ParseIP("10.0.1.20"))
+	if expected := "frontend/10.0.1.20"; id != expected {
+		t.Errorf("%s expected; got %s", expected, id)
+	}
+
+	pool, ip, err := parseIPAllocationID(id)
+	if err != nil {
+		t.Fatalf("parseIPAllocationID() returned unexpected error %v", err)
+	}
+
+	if pool != "frontend" {
+		t.Errorf("%s expected; got %s", "frontend", pool)
+	}
+
+	if !ip.Equal(net.ParseIP("10.0.1.20")) {
+		t.Errorf("%s expected; got %s", "10.0.1.20", ip)
+	}
+}
+
+func TestParseIPAllocationIDInvalid(t *testing.T) {
+	for _, id := range []string{"", "frontend", "/10.0.1.20", "frontend/foo"} {
+		if _, _, err := parseIPAllocationID(id); err == nil {
+			t.Errorf("error expected for %q; got nil", id)
+		}
+	}
+}
+
+func TestLookupIPPool(t *testing.T) {
+	pools := map[string]*ipman.Pool{
+		"frontend": &ipman.Pool{Name: "frontend"},
+	}
+
+	pool, err := lookupIPPool(pools, "frontend")
+	if err != nil {
+		t.Fatalf("lookupIPPool() returned unexpected error %v", err)
+	}
+
+	if pool.Name != "frontend" {
+		t.Errorf("%s expected; got %s", "frontend", pool.Name)
+	}
+
+	if _, err := lookupIPPool(pools, "backend"); err == nil {
+		t.Errorf("error expected for an undeclared pool; got nil")
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Orders UCS can hand out the addresses of a pool in: "default" picks them
+// at random, "sequential" from the lowest one up.
+const (
+	ASSIGNMENT_ORDER_DEFAULT    = "default"
+	ASSIGNMENT_ORDER_SEQUENTIAL = "sequential"
+)
+
+// The OUI Cisco reserves for the MAC addresses of UCS pools, as a string
+// and as the upper 24 bits of an address.
+const (
+	CISCO_UCS_OUI      = "00:25:B5"
+	CISCO_UCS_OUI_BITS = 0x0025B5
+)
+
+// A MAC pool (macpoolPool), e.g. org-root/mac-pool-esx, along with its
+// blocks of addresses (macpoolBlock). Blocks are added and removed one by
+// one, leaving the pool and the other blocks untouched.
+func resourceUcsMacPool() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceUcsMacPoolCreate,
+		Read:   resourceUcsMacPoolRead,
+		Update: resourceUcsMacPoolUpdate,
+		Delete: resourceUcsMacPoolDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceUcsMacPoolImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"org": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "org-root",
+				ForceNew:    true,
+				Description: "DN of the organization the MAC pool belongs to",
+			},
+			"descr": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"assignment_order": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      ASSIGNMENT_ORDER_DEFAULT,
+				ValidateFunc: validateAssignmentOrder,
+				Description:  "Order UCS assigns the addresses in: default or sequential",
+			},
+			"block": &schema.Schema{
+				Type:     schema.TypeSet,
+				Required: true,
+				MinItems: 1,
+				Set:      hashMacPoolBlock,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"from": &schema.Schema{
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateMAC,
+							StateFunc:    normalizeMAC,
+						},
+						"to": &schema.Schema{
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateMAC,
+							StateFunc:    normalizeMAC,
+						},
+					},
+				},
+			},
+			"cisco_oui_only": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Whether the blocks must lie within the " + CISCO_UCS_OUI + " OUI",
+			},
+			"size": &schema.Schema{
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"assigned": &schema.Schema{
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"dn": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceUcsMacPoolCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsMacPoolCreate(...)\n")
+
+	pool := ucsclient.NewMacpoolPool(d.Get("org").(string), d.Get("name").(string)).
+		Set("descr", d.Get("descr").(string)).
+		Set("assignmentOrder", d.Get("assignment_order").(string)).
+		SetStatus(ucsclient.StatusCreated)
+
+	raw := setList(d.Get("block"))
+	if err := checkMacPoolBlocks(raw, d.Get("cisco_oui_only").(bool)); err != nil {
+		return err
+	}
+
+	for _, child := range diffChildren(nil, expandMacPoolBlocks(pool.Dn(), raw)) {
+		pool.AddChild(child)
+	}
+
+	client.Logger.Info("Creating MAC pool \"%s\"\n", pool.Dn())
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{pool.Dn(): pool}, true)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(pool.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsMacPoolCreate(...)\n")
+	return resourceUcsMacPoolRead(d, meta)
+}
+
+func resourceUcsMacPoolRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsMacPoolRead(...)\n")
+
+	pool, err := client.ResolveDn(d.Id(), true)
+	if err != nil {
+		return err
+	}
+
+	// The MAC pool was deleted outside of Terraform.
+	if pool == nil {
+		d.SetId("")
+		return nil
+	}
+
+	blocks := make([]interface{}, 0)
+	for _, child := range pool.ChildrenByClass("macpoolBlock") {
+		blocks = append(blocks, map[string]interface{}{
+			"from": normalizeMAC(child.Get("from")),
+			"to":   normalizeMAC(child.Get("to")),
+		})
+	}
+
+	org, name := splitMacPoolDn(pool.Dn())
+	d.Set("name", name)
+	d.Set("org", org)
+	d.Set("descr", pool.Get("descr"))
+	d.Set("assignment_order", pool.Get("assignmentOrder"))
+	d.Set("block", blocks)
+	d.Set("size", atoiOrZero(pool.Get("size")))
+	d.Set("assigned", atoiOrZero(pool.Get("assigned")))
+	d.Set("dn", pool.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsMacPoolRead(...)\n")
+	return nil
+}
+
+// Changes the description and assignment order of the pool, adds the new
+// blocks and removes the ones which are gone in a single transaction.
+func resourceUcsMacPoolUpdate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsMacPoolUpdate(...)\n")
+
+	oldBlocks, newBlocks := d.GetChange("block")
+	if err := checkMacPoolBlocks(setList(newBlocks), d.Get("cisco_oui_only").(bool)); err != nil {
+		return err
+	}
+
+	pool := ucsclient.NewManagedObject("macpoolPool", d.Id()).
+		Set("descr", d.Get("descr").(string)).
+		Set("assignmentOrder", d.Get("assignment_order").(string)).
+		SetStatus(ucsclient.StatusModified)
+
+	old := expandMacPoolBlocks(d.Id(), setList(oldBlocks))
+	for _, child := range diffChildren(old, expandMacPoolBlocks(d.Id(), setList(newBlocks))) {
+		pool.AddChild(child)
+	}
+
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{pool.Dn(): pool}, true)
+	if err != nil {
+		return err
+	}
+
+	client.Logger.Debug("Exiting resourceUcsMacPoolUpdate(...)\n")
+	return resourceUcsMacPoolRead(d, meta)
+}
+
+func resourceUcsMacPoolDelete(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsMacPoolDelete(...)\n")
+
+	pool := ucsclient.NewManagedObject("macpoolPool", d.Id()).SetStatus(ucsclient.StatusDeleted)
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{pool.Dn(): pool}, false)
+	if err != nil {
+		return err
+	}
+
+	d.SetId("")
+
+	client.Logger.Debug("Exiting resourceUcsMacPoolDelete(...)\n")
+	return nil
+}
+
+// Imports an existing MAC pool given its DN, e.g.
+// `terraform import ucs_mac_pool.x org-root/mac-pool-esx`. Pools outside of
+// the Cisco OUI need cisco_oui_only = false in the configuration.
+func resourceUcsMacPoolImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if org, _ := splitMacPoolDn(d.Id()); org == "" {
+		return nil, fmt.Errorf("Invalid MAC pool DN %q: expected e.g. org-root/mac-pool-esx", d.Id())
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+// Builds the blocks of the MAC pool identified by poolDn, keyed by DN.
+func expandMacPoolBlocks(poolDn string, blocks []interface{}) map[string]*ucsclient.ManagedObject {
+	children := make(map[string]*ucsclient.ManagedObject)
+	for _, raw := range blocks {
+		block := raw.(map[string]interface{})
+		mo := ucsclient.NewMacpoolBlock(poolDn, normalizeMAC(block["from"]), normalizeMAC(block["to"]))
+		children[mo.Dn()] = mo
+	}
+	return children
+}
+
+// Hashes a block by its normalized addresses, so that the ones written in
+// lower case or with dashes match the ones UCS reports.
+func hashMacPoolBlock(v interface{}) int {
+	block := v.(map[string]interface{})
+	return schema.HashString(normalizeMAC(block["from"]) + "-" + normalizeMAC(block["to"]))
+}
+
+// A block of MAC addresses as 48-bit integers, from and to included.
+type macRange struct {
+	from, to uint64
+}
+
+// Checks that each block starts before it ends, that no two blocks overlap
+// and, if ciscoOnly is set, that all of them lie within the Cisco UCS OUI.
+func checkMacPoolBlocks(blocks []interface{}, ciscoOnly bool) error {
+	ranges := make([]macRange, 0, len(blocks))
+	names := make(map[macRange]string)
+	for _, raw := range blocks {
+		block := raw.(map[string]interface{})
+		from, err := parseMAC(block["from"].(string))
+		if err != nil {
+			return err
+		}
+
+		to, err := parseMAC(block["to"].(string))
+		if err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("%s-%s", normalizeMAC(block["from"]), normalizeMAC(block["to"]))
+		if from > to {
+			return fmt.Errorf("Invalid MAC pool block %s: from must not be greater than to", name)
+		}
+
+		if ciscoOnly && (from>>24 != CISCO_UCS_OUI_BITS || to>>24 != CISCO_UCS_OUI_BITS) {
+			return fmt.Errorf("MAC pool block %s lies outside of the Cisco UCS OUI %s; set cisco_oui_only = false to allow it", name, CISCO_UCS_OUI)
+		}
+
+		r := macRange{from, to}
+		ranges = append(ranges, r)
+		names[r] = name
+	}
+
+	sort.Sort(byFrom(ranges))
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i].from <= ranges[i-1].to {
+			return fmt.Errorf("MAC pool blocks %s and %s overlap", names[ranges[i-1]], names[ranges[i]])
+		}
+	}
+	return nil
+}
+
+type byFrom []macRange
+
+func (r byFrom) Len() int           { return len(r) }
+func (r byFrom) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r byFrom) Less(i, j int) bool { return r[i].from < r[j].from }
+
+// Parses a 48-bit MAC address into an integer.
+func parseMAC(s string) (uint64, error) {
+	hw, err := net.ParseMAC(s)
+	if err != nil || len(hw) != 6 {
+		return 0, fmt.Errorf("Invalid MAC address %q: expected e.g. 00:25:B5:00:00:00", s)
+	}
+
+	var mac uint64
+	for _, b := range hw {
+		mac = mac<<8 | uint64(b)
+	}
+	return mac, nil
+}
+
+// Returns the MAC address the way UCS reports it, colon separated and in
+// upper case. Invalid addresses are returned as is.
+func normalizeMAC(v interface{}) string {
+	hw, err := net.ParseMAC(v.(string))
+	if err != nil {
+		return v.(string)
+	}
+	return strings.ToUpper(hw.String())
+}
+
+// Splits the DN of a MAC pool into the DN of its organization and its name,
+// e.g. org-root/mac-pool-esx into org-root and esx. Returns blank strings if
+// dn is not the DN of a MAC pool.
+func splitMacPoolDn(dn string) (org, name string) {
+	i := strings.LastIndex(dn, "/")
+	if i <= 0 || !strings.HasPrefix(dn[i+1:], "mac-pool-") || dn[i+1:] == "mac-pool-" {
+		return "", ""
+	}
+	return dn[:i], strings.TrimPrefix(dn[i+1:], "mac-pool-")
+}
+
+func validateMAC(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := parseMAC(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %v", k, err))
+	}
+	return
+}
+
+func validateAssignmentOrder(v interface{}, k string) (ws []string, errors []error) {
+	return validateOneOf([]string{ASSIGNMENT_ORDER_DEFAULT, ASSIGNMENT_ORDER_SEQUENTIAL}, v, k)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+)
+
+func macPoolBlock(from, to string) map[string]interface{} {
+	return map[string]interface{}{"from": from, "to": to}
+}
+
+func TestCheckMacPoolBlocks(t *testing.T) {
+	valid := []interface{}{
+		macPoolBlock("00:25:B5:00:00:00", "00:25:B5:00:00:FF"),
+		macPoolBlock("00:25:b5:00:01:00", "00:25:b5:00:01:00"),
+	}
+	if err := checkMacPoolBlocks(valid, true); err != nil {
+		t.Errorf("no error expected; got %v", err)
+	}
+
+	tests := map[string][]interface{}{
+		"overlapping blocks": []interface{}{
+			macPoolBlock("00:25:B5:00:00:00", "00:25:B5:00:00:FF"),
+			macPoolBlock("00:25:B5:00:00:FF", "00:25:B5:00:01:FF"),
+		},
+		"a block ending before it starts": []interface{}{
+			macPoolBlock("00:25:B5:00:00:FF", "00:25:B5:00:00:00"),
+		},
+		"a block outside of the Cisco OUI": []interface{}{
+			macPoolBlock("00:25:B5:FF:FF:00", "00:25:B6:00:00:FF"),
+		},
+		"an invalid address": []interface{}{
+			macPoolBlock("00:25:B5:00:00", "00:25:B5:00:00:FF"),
+		},
+	}
+
+	for name, blocks := range tests {
+		if err := checkMacPoolBlocks(blocks, true); err == nil {
+			t.Errorf("error expected for %s; got nil", name)
+		}
+	}
+
+	other := []interface{}{macPoolBlock("02:00:00:00:00:00", "02:00:00:00:00:FF")}
+	if err := checkMacPoolBlocks(other, false); err != nil {
+		t.Errorf("no error expected outside of the Cisco OUI if allowed; got %v", err)
+	}
+}
+
+func TestExpandMacPoolBlocks(t *testing.T) {
+	blocks := expandMacPoolBlocks("org-root/mac-pool-esx", []interface{}{
+		macPoolBlock("00:25:b5:00:00:00", "00:25:b5:00:00:ff"),
+	})
+
+	dn := "org-root/mac-pool-esx/block-00:25:B5:00:00:00-00:25:B5:00:00:FF"
+	mo, ok := blocks[dn]
+	if !ok || len(blocks) != 1 {
+		t.Fatalf("%s expected; got %v", dn, blocks)
+	}
+
+	if mo.ClassId != "macpoolBlock" {
+		t.Errorf("%s expected; got %s", "macpoolBlock", mo.ClassId)
+	}
+
+	if from := mo.Get("from"); from != "00:25:B5:00:00:00" {
+		t.Errorf("%s expected; got %s", "00:25:B5:00:00:00", from)
+	}
+}
+
+func TestSplitMacPoolDn(t *testing.T) {
+	org, name := splitMacPoolDn("org-root/org-a/mac-pool-esx")
+	if org != "org-root/org-a" || name != "esx" {
+		t.Errorf("%s, %s expected; got %s, %s", "org-root/org-a", "esx", org, name)
+	}
+
+	for _, dn := range []string{"org-root", "org-root/mac-pool-", "org-root/ip-pool-esx", "mac-pool-esx"} {
+		if org, _ := splitMacPoolDn(dn); org != "" {
+			t.Errorf("blank organization expected for %s; got %s", dn, org)
+		}
+	}
+}
+
+func TestHashMacPoolBlock(t *testing.T) {
+	expected := hashMacPoolBlock(macPoolBlock("00:25:B5:00:00:00", "00:25:B5:00:00:FF"))
+	for _, block := range []map[string]interface{}{
+		macPoolBlock("00:25:b5:00:00:00", "00:25:b5:00:00:ff"),
+		macPoolBlock("00-25-B5-00-00-00", "00-25-b5-00-00-ff"),
+	} {
+		if actual := hashMacPoolBlock(block); actual != expected {
+			t.Errorf("%d expected for %v; got %d", expected, block, actual)
+		}
+	}
+}
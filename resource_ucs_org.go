@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// An organization (orgOrg), e.g. org-root/org-tenant-a, which service
+// profiles and pools can be placed into. Organizations can be nested by
+// setting parent_dn to the dn of another one.
+func resourceUcsOrg() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceUcsOrgCreate,
+		Read:   resourceUcsOrgRead,
+		Update: resourceUcsOrgUpdate,
+		Delete: resourceUcsOrgDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceUcsOrgImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"parent_dn": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "org-root",
+				ForceNew:    true,
+				Description: "DN of the organization the organization belongs to",
+			},
+			"descr": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"dn": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceUcsOrgCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsOrgCreate(...)\n")
+
+	org := ucsclient.NewOrgOrg(d.Get("parent_dn").(string), d.Get("name").(string)).
+		Set("descr", d.Get("descr").(string)).
+		SetStatus(ucsclient.StatusCreated)
+
+	client.Logger.Info("Creating organization \"%s\"\n", org.Dn())
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{org.Dn(): org}, false)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(org.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsOrgCreate(...)\n")
+	return resourceUcsOrgRead(d, meta)
+}
+
+func resourceUcsOrgRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsOrgRead(...)\n")
+
+	org, err := client.ResolveDn(d.Id(), false)
+	if err != nil {
+		return err
+	}
+
+	// The organization was deleted outside of Terraform.
+	if org == nil {
+		d.SetId("")
+		return nil
+	}
+
+	parentDn, name := splitOrgDn(org.Dn())
+	d.Set("name", name)
+	d.Set("parent_dn", parentDn)
+	d.Set("descr", org.Get("descr"))
+	d.Set("dn", org.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsOrgRead(...)\n")
+	return nil
+}
+
+// Only the description can be changed in place; renaming or moving an
+// organization requires a new one (see ForceNew in the schema).
+func resourceUcsOrgUpdate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsOrgUpdate(...)\n")
+
+	if d.HasChange("descr") {
+		org := ucsclient.NewManagedObject("orgOrg", d.Id()).
+			Set("descr", d.Get("descr").(string)).
+			SetStatus(ucsclient.StatusModified)
+
+		_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{org.Dn(): org}, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	client.Logger.Debug("Exiting resourceUcsOrgUpdate(...)\n")
+	return resourceUcsOrgRead(d, meta)
+}
+
+// Deleting an organization deletes everything it holds in UCS, including
+// sub-organizations and service profiles.
+func resourceUcsOrgDelete(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsOrgDelete(...)\n")
+
+	org := ucsclient.NewManagedObject("orgOrg", d.Id()).SetStatus(ucsclient.StatusDeleted)
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{org.Dn(): org}, false)
+	if err != nil {
+		return err
+	}
+
+	d.SetId("")
+
+	client.Logger.Debug("Exiting resourceUcsOrgDelete(...)\n")
+	return nil
+}
+
+// Imports an existing organization given its DN, e.g.
+// `terraform import ucs_org.x org-root/org-a/org-b`.
+func resourceUcsOrgImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if parentDn, _ := splitOrgDn(d.Id()); parentDn == "" {
+		return nil, fmt.Errorf("Invalid organization DN %q: expected e.g. org-root/org-a", d.Id())
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+// Splits the DN of an organization into the DN of its parent and its name,
+// e.g. org-root/org-a/org-b into org-root/org-a and b. Returns blank
+// strings if dn is not the DN of a (non-root) organization.
+func splitOrgDn(dn string) (parentDn, name string) {
+	i := strings.LastIndex(dn, "/")
+	if i <= 0 || !strings.HasPrefix(dn[i+1:], "org-") || dn[i+1:] == "org-" {
+		return "", ""
+	}
+	return dn[:i], strings.TrimPrefix(dn[i+1:], "org-")
+}
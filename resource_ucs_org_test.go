@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSplitOrgDn(t *testing.T) {
+	tests := []struct {
+		dn       string
+		parentDn string
+		name     string
+	}{
+		{"org-root/org-a", "org-root", "a"},
+		{"org-root/org-a/org-b-c", "org-root/org-a", "b-c"},
+		{"org-root", "", ""},
+		{"org-root/ls-foo", "", ""},
+		{"org-root/org-", "", ""},
+	}
+
+	for _, test := range tests {
+		parentDn, name := splitOrgDn(test.dn)
+		if parentDn != test.parentDn || name != test.name {
+			t.Errorf("%s, %s expected for %s; got %s, %s", test.parentDn, test.name, test.dn, parentDn, name)
+		}
+	}
+}
@@ -1,18 +1,32 @@
 package main
 
 import (
-	"errors"
+	"bytes"
+	"fmt"
 	"net"
-	"sync"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/CiscoUcs/UCS-Terraform/ipman"
 	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
-type sessionCallback func(*ucsclient.UCSClient) error
+// States a service profile goes through while UCS associates it with a
+// server, as reported by serviceProfileStateRefreshFunc.
+const (
+	SP_STATE_PENDING = "pending"
+	SP_STATE_READY   = "ready"
+)
 
-var sessionMutex = sync.Mutex{}
+// How the IPv6 address of a dual-stack vNIC is picked: derived from the MAC
+// UCS assigned to the vNIC or the next free address of its ipv6_cidr.
+const (
+	IPV6_ALLOCATION_EUI64      = "eui64"
+	IPV6_ALLOCATION_SEQUENTIAL = "sequential"
+)
 
 func resourceUcsServiceProfile() *schema.Resource {
 	return &schema.Resource{
@@ -21,10 +35,17 @@ func resourceUcsServiceProfile() *schema.Resource {
 		Read:          resourceUcsServiceProfileRead,
 		Update:        resourceUcsServiceProfileUpdate,
 		Delete:        resourceUcsServiceProfileDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceUcsServiceProfileImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+		},
 		Schema: map[string]*schema.Schema{
 			"service_profile_template": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Template the profile is instantiated from. Required on creation; removing it afterwards unbinds the profile from the template",
 			},
 			"name": &schema.Schema{
 				Type:     schema.TypeString,
@@ -33,11 +54,58 @@ func resourceUcsServiceProfile() *schema.Resource {
 			"target_org": &schema.Schema{
 				Type:     schema.TypeString,
 				Required: true,
+				ForceNew: true,
 			},
 			"dn": &schema.Schema{
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"power_state": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validatePowerState,
+				Description:  "Power state of the associated server: up, down, soft-shut-down, cycle-immediate or hard-reset-immediate",
+			},
+			"fail_on_fault_severity": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateFaultSeverity,
+				Description:  "Fail the creation if UCS reports a fault of this severity or higher (warning, minor, major or critical)",
+			},
+			"faults": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"code": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"severity": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"cause": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"descr": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"created": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+			"config_issues": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Configuration issues reported by UCS, keyed by area (server, network, storage, vnic, iscsi, warnings)",
+			},
 			"metadata": &schema.Schema{
 				Type:        schema.TypeMap,
 				Optional:    true,
@@ -51,6 +119,7 @@ func resourceUcsServiceProfile() *schema.Resource {
 						"name": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 						"cidr": &schema.Schema{
 							Type:     schema.TypeString,
@@ -61,9 +130,71 @@ func resourceUcsServiceProfile() *schema.Resource {
 							Computed: true,
 						},
 						"ip": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							Computed:     true,
+							ValidateFunc: validateIP,
+							Description:  "Pins the vNIC to this address of its cidr instead of allocating one",
+						},
+						"exclude": &schema.Schema{
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Addresses (IPs or CIDRs) which must never be allocated, e.g. gateways, VIPs or DHCP ranges",
+						},
+						"range_start": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validateIP,
+							Description:  "First address of the cidr which may be allocated",
+						},
+						"range_end": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validateIP,
+							Description:  "Last address of the cidr which may be allocated",
+						},
+						"ipv6_cidr": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validateIPv6CIDR,
+							Description:  "IPv6 prefix the ipv6 address of a dual-stack vNIC is allocated from",
+						},
+						"ipv6_allocation": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      IPV6_ALLOCATION_EUI64,
+							ValidateFunc: validateIPv6Allocation,
+							Description:  "How the ipv6 address, or the ip of an IPv6 cidr, is picked: eui64 (derived from the MAC) or sequential",
+						},
+						"ipv6": &schema.Schema{
 							Type:     schema.TypeString,
 							Computed: true,
 						},
+						"netmask": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"prefix_length": &schema.Schema{
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"gateway": &schema.Schema{
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Gateway of the ip_pool of the provider named after the vNIC",
+						},
+						"dns_servers": &schema.Schema{
+							Type:        schema.TypeList,
+							Computed:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "DNS servers of the ip_pool of the provider named after the vNIC",
+						},
+						"domain": &schema.Schema{
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Domain of the ip_pool of the provider named after the vNIC",
+						},
 					},
 				},
 			},
@@ -73,7 +204,7 @@ func resourceUcsServiceProfile() *schema.Resource {
 
 // Creates a new Service Profile using the information available in the Resource Data.
 // `meta` in this case is a pointer to a ucsclient.UCSClient.
-func resourceUcsServiceProfileCreate(d *schema.ResourceData, meta interface{}) error {
+func resourceUcsServiceProfileCreate(d *schema.ResourceData, meta interface{}) (err error) {
 	sp := &ucsclient.ServiceProfile{
 		Name:         d.Get("name").(string),
 		Template:     d.Get("service_profile_template").(string),
@@ -82,76 +213,117 @@ func resourceUcsServiceProfileCreate(d *schema.ResourceData, meta interface{}) e
 		Hierarchical: false,
 	}
 
-	vnics := d.Get("vNIC")
-	vnicList := vnics.([]interface{})
-	for _, item := range vnicList {
-		vnic := item.(map[string]interface{})
-		sp.VNICs = append(sp.VNICs, ucsclient.VNIC{
-			Name: vnic["name"].(string),
-			CIDR: vnic["cidr"].(string),
-		})
+	if sp.Template == "" {
+		return fmt.Errorf("service_profile_template is required to create service profile \"%s\"", sp.Name)
+	}
 
+	for _, vnic := range fetchVnicsFromResourceData(d) {
 		// Validate the vNIC's CIDR and return error if anything.
-		err := validateCIDR(sp.VNICs[len(sp.VNICs)-1].CIDR)
+		err := validateCIDR(vnic.CIDR)
+		if err != nil {
+			return err
+		}
+
+		err = applyPoolSettings(meta.(*providerMeta).pools, &vnic)
 		if err != nil {
 			return err
 		}
+		sp.VNICs = append(sp.VNICs, vnic)
 	}
 
-	c := meta.(*ucsclient.UCSClient)
+	client := meta.(*providerMeta).client
+	allocator := meta.(*providerMeta).allocator
 
-	err := withSession(c, func(client *ucsclient.UCSClient) error {
-		d.Partial(true)
-		if d.HasChange("name") {
-			client.Logger.Info("Creating Profile \"%s\" from template \"%s\"\n", sp.Name, sp.Template)
-			created, err := client.CreateServiceProfile(sp)
-			if err != nil {
-				client.Logger.Warn("Failed to create profile \"%s\": %s\n", sp.Name, err)
-				return err
-			}
+	// Pinned IPs are claimed before anything is created in UCS, and given
+	// back if the profile can't be created along with its vNICs.
+	claimed := make([]ucsclient.VNIC, 0, len(sp.VNICs))
+	defer func() {
+		if err != nil {
+			releaseClaimedIPs(allocator, claimed)
+		}
+	}()
+
+	for _, vnic := range sp.VNICs {
+		err := claimVnicIP(allocator, meta.(*providerMeta).pools, sp.DN(), vnic)
+		if err != nil {
+			return err
+		}
 
-			if !created {
-				err = errors.New("Failed to create service profile\n")
-				client.Logger.Error(err.Error())
+		if vnic.Ip != nil {
+			claimed = append(claimed, vnic)
+		}
+	}
+
+	d.Partial(true)
+	if d.HasChange("name") {
+		client.Logger.Info("Creating Profile \"%s\" from template \"%s\"\n", sp.Name, sp.Template)
+		created, err := client.CreateServiceProfile(sp)
+		if err != nil {
+			client.Logger.Warn("Failed to create profile \"%s\": %s\n", sp.Name, err)
+			return err
+		}
+
+		if !created {
+			err = fmt.Errorf("Failed to create service profile \"%s\" from template \"%s\": UCS did not report it as created", sp.Name, sp.Template)
+			client.Logger.Error("%s\n", err)
+			return err
+		}
+
+		client.Logger.Info("Profile \"%s\" was created\n", sp.Name)
+		d.SetId(sp.Name) // tell Terraform that a profile was created. The existence of a non-blank ID is what tells Terraform that a profile was created
+		d.Set("dn", sp.DN())
+		d.SetPartial("name")
+
+		if state, ok := d.GetOk("power_state"); ok {
+			client.Logger.Info("Setting power state of profile \"%s\" to %s\n", sp.Name, state)
+			err = client.SetPowerState(sp.DN(), state.(string))
+			if err != nil {
 				return err
 			}
+			d.SetPartial("power_state")
+		}
 
-			client.Logger.Info("Profile \"%s\" was created\n", sp.Name)
-			d.SetId(sp.Name) // tell Terraform that a profile was created. The existence of a non-blank ID is what tells Terraform that a profile was created
-			d.Set("dn", sp.DN())
-			d.SetPartial("name")
+		// Provisioners must not run against a server which is still being associated.
+		client.Logger.Info("Waiting for profile \"%s\" to be associated\n", sp.Name)
+		ready, err := waitForServiceProfile(client, sp.DN(), d.Timeout(schema.TimeoutCreate))
+		if err != nil {
+			return err
 		}
 
-		if d.HasChange("vNIC") {
-			vnics := make([]map[string]string, len(sp.VNICs))
-			// Assign an IP to each of the vNICs in the Service Profile.
-			for i, vnic := range sp.VNICs {
-				ip, err := ipman.GenerateIP("inventory-"+vnic.Name, vnic.CIDR)
-				if err != nil {
-					return err
-				}
-				vnic.Ip = ip
+		if severity := d.Get("fail_on_fault_severity").(string); severity != "" {
+			faults := faultsWithSeverity(ready.Faults, severity)
+			if len(faults) > 0 {
+				ready.Faults = faults
+				return fmt.Errorf("Service profile %s has faults of severity %s or higher: %s", sp.DN(), severity, describeServiceProfile(ready))
+			}
+		}
 
-				vnics[i] = map[string]string{
-					"name": vnic.Name,
-					"ip":   vnic.Ip.String(),
-					"cidr": vnic.CIDR,
+		// UCS assigns the MACs, which EUI-64 IPv6 addresses are derived from.
+		for i := range sp.VNICs {
+			for _, remote := range ready.VNICs {
+				if remote.Name == sp.VNICs[i].Name {
+					sp.VNICs[i].Mac = remote.Mac
 				}
 			}
-			d.Set("vNIC", vnics)
-			d.SetPartial("vNIC")
 		}
+	}
 
-		d.Partial(false)
-		client.Logger.Debug("Exiting resourceUcsServiceProfileCreate(...)\n")
-		return nil
-	})
-
-	if err != nil {
-		return err
+	if d.HasChange("vNIC") {
+		vnics, err := assignVnicIPs(allocator, meta.(*providerMeta).pools, sp.DN(), sp.VNICs)
+		if err != nil {
+			return err
+		}
+		d.Set("vNIC", vnics)
+		d.SetPartial("vNIC")
 	}
 
-	return resourceUcsServiceProfileRead(d, c)
+	// The pinned IPs are in the state now, Delete releases them.
+	claimed = nil
+
+	d.Partial(false)
+	client.Logger.Debug("Exiting resourceUcsServiceProfileCreate(...)\n")
+
+	return resourceUcsServiceProfileRead(d, meta)
 }
 
 // Fetches general information of the Service Profile from UCS.
@@ -160,108 +332,839 @@ func resourceUcsServiceProfileCreate(d *schema.ResourceData, meta interface{}) e
 // If the Service Profile is no longer available this will remove it from
 // the tfstate file.
 func resourceUcsServiceProfileRead(d *schema.ResourceData, meta interface{}) error {
-	c := meta.(*ucsclient.UCSClient)
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsServiceProfileRead(...)\n")
 
-	err := withSession(c, func(client *ucsclient.UCSClient) error {
-		client.Logger.Debug("Entering resourceUcsServiceProfileRead(...)\n")
+	//1. Query the UCS for the profile
+	dn := d.Get("dn").(string)
+	sp, err := client.ConfigResolveDN(dn)
 
-		//1. Query the UCS for the profile
-		dn := d.Get("dn").(string)
-		sp, err := client.ConfigResolveDN(dn)
+	if err != nil {
+		return err
+	}
 
+	// If the service profile could not be found we assume that it does not exist anymore
+	// We tell Terraform so by setting its id to a blank string.
+	if sp == nil {
+		d.SetId("")
+		return nil
+	}
+
+	// Fetch vNIC info from ResourceData
+	vNicsFromResourceData := fetchVnicsFromResourceData(d)
+	for i := range vNicsFromResourceData {
+		err = applyPoolSettings(meta.(*providerMeta).pools, &vNicsFromResourceData[i])
 		if err != nil {
 			return err
 		}
+	}
 
-		// If the service profile could not be found we assume that it does not exist anymore
-		// We tell Terraform so by setting its id to a blank string.
-		if sp == nil {
-			d.SetId("")
-			return nil
-		}
-
-		// Fetch vNIC info from ResourceData
-		vNicsFromResourceData := fetchVnicsFromResourceData(d)
+	// Merge the UCS vNIC info with the ResourceData vNIC info
+	vnics := mergeVnics(vNicsFromResourceData, sp.VNICs)
 
-		// Merge the UCS vNIC info with the ResourceData vNIC info
-		vnics := mergeVnics(vNicsFromResourceData, sp.VNICs)
+	// Update the information related to the service profile fetched from UCS in Terraform.
+	d.Set("name", sp.Name)
+	d.Set("service_profile_template", sp.Template)
+	d.Set("target_org", sp.TargetOrg)
+	d.Set("vNIC", vnics)
+	d.Set("faults", flattenFaults(sp.Faults))
 
-		// Update the information related to the service profile fetched from UCS in Terraform.
-		d.Set("name", sp.Name)
-		d.Set("service_profile_template", sp.Template)
-		d.Set("target_org", sp.TargetOrg)
-		d.Set("vNIC", vnics)
-
-		d.SetConnInfo(map[string]string{
-			"type": "ssh",
-			"host": d.Get("vNIC.0.ip").(string),
-		})
+	// cycle-immediate and the like are one-off actions after which UCS
+	// reports the resulting state, so don't show them as a difference.
+	if !powerStatesEquivalent(d.Get("power_state").(string), sp.PowerState) {
+		d.Set("power_state", sp.PowerState)
+	}
+	d.Set("config_issues", sp.ConfigIssues)
 
-		client.Logger.Debug("Exiting resourceUcsServiceProfileRead(...)\n")
-		return nil
+	d.SetConnInfo(map[string]string{
+		"type": "ssh",
+		"host": d.Get("vNIC.0.ip").(string),
 	})
 
-	if err != nil {
-		return err
-	}
-
+	client.Logger.Debug("Exiting resourceUcsServiceProfileRead(...)\n")
 	return nil
 }
 
 // Updates the Service Profile in UCS.
+// The profile can be renamed, bound to a different template, unbound from
+// its template and powered on or off in place. Its vNICs are readdressed in
+// place as well: new IPs are claimed or allocated before the old ones are
+// released. Changing its target_org or the names of its vNICs requires a
+// new profile (see ForceNew in the schema).
 func resourceUcsServiceProfileUpdate(d *schema.ResourceData, meta interface{}) error {
-	c := meta.(*ucsclient.UCSClient)
+	c := meta.(*providerMeta).client
+	allocator := meta.(*providerMeta).allocator
 	c.Logger.Debug("Entering resourceUcsServiceProfileUpdate(...)\n")
+
+	d.Partial(true)
+	if d.HasChange("name") {
+		old, name := d.GetChange("name")
+		oldDn := d.Get("dn").(string)
+		c.Logger.Info("Renaming profile \"%s\" to \"%s\"\n", old, name)
+		mo, err := c.ConfigConfRename(oldDn, name.(string), false)
+		if err != nil {
+			return err
+		}
+
+		dn := mo.Dn()
+		if dn == "" {
+			dn = d.Get("target_org").(string) + "/ls-" + name.(string)
+		}
+		d.SetId(name.(string))
+		d.Set("dn", dn)
+		d.SetPartial("name")
+
+		// The IPs are owned by the DNs of the vNICs, which changed too.
+		oldVnics, _ := d.GetChange("vNIC")
+		err = moveVnicIPs(allocator, oldDn, dn, expandVnics(oldVnics.([]interface{})))
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("service_profile_template") {
+		template := d.Get("service_profile_template").(string)
+		if template == "" {
+			c.Logger.Info("Unbinding profile \"%s\" from its template\n", d.Id())
+		} else {
+			c.Logger.Info("Binding profile \"%s\" to template \"%s\"\n", d.Id(), template)
+		}
+
+		err := c.SetServiceProfileTemplate(d.Get("dn").(string), template)
+		if err != nil {
+			return err
+		}
+		d.SetPartial("service_profile_template")
+	}
+
+	// vNICs can't be renamed (see ForceNew in the schema), so this is a
+	// change of addressing or an imported vNIC whose CIDR is now known.
+	if d.HasChange("vNIC") {
+		old, _ := d.GetChange("vNIC")
+		oldVnics := make(map[string]ucsclient.VNIC)
+		for _, vnic := range expandVnics(old.([]interface{})) {
+			oldVnics[vnic.Name] = vnic
+		}
+
+		// The IPs replaced go back to the inventory, but only once the new
+		// ones have been claimed and assigned: the state keeps the old ones
+		// if anything fails.
+		vnics := fetchVnicsFromResourceData(d)
+		claimed, stale, err := readdressVnics(allocator, meta.(*providerMeta).pools, d.Get("dn").(string), oldVnics, vnics)
+		if err != nil {
+			return err
+		}
+
+		ips, err := assignVnicIPs(allocator, meta.(*providerMeta).pools, d.Get("dn").(string), vnics)
+		if err != nil {
+			releaseClaimedIPs(allocator, claimed)
+			return err
+		}
+
+		for _, vnic := range stale {
+			err = releaseVnicIPs(allocator, vnic)
+			if err != nil {
+				return err
+			}
+		}
+		d.Set("vNIC", ips)
+		d.SetPartial("vNIC")
+	}
+
+	if d.HasChange("power_state") {
+		state := d.Get("power_state").(string)
+		c.Logger.Info("Setting power state of profile \"%s\" to %s\n", d.Id(), state)
+		err := c.SetPowerState(d.Get("dn").(string), state)
+		if err != nil {
+			return err
+		}
+		d.SetPartial("power_state")
+	}
+	d.Partial(false)
+
 	c.Logger.Debug("Exiting resourceUcsServiceProfileUpdate(...)\n")
-	return resourceUcsServiceProfileRead(d, c)
+	return resourceUcsServiceProfileRead(d, meta)
 }
 
 // Deletes a given Service Profile, using its "dn" as the identifier.
 func resourceUcsServiceProfileDelete(d *schema.ResourceData, meta interface{}) error {
-	c := meta.(*ucsclient.UCSClient)
-	return withSession(c, func(client *ucsclient.UCSClient) error {
-		client.Logger.Debug("Entering resourceUcsServiceProfileDelete(...)\n")
+	client := meta.(*providerMeta).client
+	allocator := meta.(*providerMeta).allocator
+	client.Logger.Debug("Entering resourceUcsServiceProfileDelete(...)\n")
+
+	name := d.Id()
+	targetOrg := d.Get("target_org").(string)
 
-		name := d.Id()
-		targetOrg := d.Get("target_org").(string)
+	// Delete the resource
+	err := client.Destroy(name, targetOrg, true)
+	if err != nil {
+		return err
+	}
 
-		// Delete the resource
-		err := client.Destroy(name, targetOrg, true)
+	// Give the IPs of the vNICs back so that other profiles can use them.
+	for _, vnic := range fetchVnicsFromResourceData(d) {
+		err = releaseVnicIPs(allocator, vnic)
 		if err != nil {
 			return err
 		}
+	}
+
+	// Tell Terraform that the resource has been successfully destroyed
+	d.SetId("")
+
+	client.Logger.Debug("Exiting resourceUcsServiceProfileDelete(...)\n")
+	return nil
+}
+
+// Imports an existing service profile given its DN, e.g.
+// `terraform import ucs_service_profile.x org-root/org-a/ls-foo`.
+// The CIDRs of its vNICs are unknown to UCS and have to be set in the
+// configuration; an IP is allocated for each of them on the next apply.
+func resourceUcsServiceProfileImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsServiceProfileImport(...)\n")
+
+	dn := d.Id()
+	if org, _ := splitServiceProfileDn(dn); org == "" {
+		return nil, fmt.Errorf("Invalid service profile DN %q: expected e.g. org-root/org-a/ls-foo", dn)
+	}
+
+	sp, err := client.ConfigResolveDN(dn)
+	if err != nil {
+		return nil, err
+	}
+
+	if sp == nil {
+		return nil, fmt.Errorf("Service profile %s does not exist", dn)
+	}
+
+	vnics := make([]map[string]string, 0, len(sp.VNICs))
+	for _, vnic := range sp.VNICs {
+		vnics = append(vnics, map[string]string{
+			"name": vnic.Name,
+			"mac":  vnic.Mac,
+		})
+	}
+
+	d.SetId(sp.Name)
+	d.Set("dn", dn)
+	d.Set("vNIC", vnics)
+
+	client.Logger.Debug("Exiting resourceUcsServiceProfileImport(...)\n")
+	return []*schema.ResourceData{d}, nil
+}
+
+// Splits the DN of a service profile into the DN of its organization and
+// its name, e.g. org-root/org-a/ls-foo into org-root/org-a and foo. Returns
+// blank strings if dn is not the DN of a service profile.
+func splitServiceProfileDn(dn string) (org, name string) {
+	i := strings.LastIndex(dn, "/")
+	if i <= 0 || !strings.HasPrefix(dn, "org-root") || !strings.HasPrefix(dn[i+1:], "ls-") || dn[i+1:] == "ls-" {
+		return "", ""
+	}
+	return dn[:i], strings.TrimPrefix(dn[i+1:], "ls-")
+}
+
+// Polls UCS until the service profile identified by dn has been associated
+// with a server and configured, UCS gives up doing so or the timeout expires.
+func waitForServiceProfile(client *ucsclient.UCSClient, dn string, timeout time.Duration) (*ucsclient.ServiceProfile, error) {
+	var last *ucsclient.ServiceProfile
+	refresh := serviceProfileStateRefreshFunc(client, dn)
+
+	conf := &resource.StateChangeConf{
+		Pending: []string{SP_STATE_PENDING},
+		Target:  []string{SP_STATE_READY},
+		Refresh: func() (interface{}, string, error) {
+			sp, state, err := refresh()
+			if sp != nil {
+				last = sp.(*ucsclient.ServiceProfile)
+			}
+			return sp, state, err
+		},
+		Timeout:    timeout,
+		Delay:      10 * time.Second,
+		MinTimeout: 5 * time.Second,
+	}
+
+	_, err := conf.WaitForState()
+	if err != nil {
+		if last != nil && !last.Failed() {
+			return nil, fmt.Errorf("%s (%s)", err, describeServiceProfile(last))
+		}
+		return nil, err
+	}
+	return last, nil
+}
 
-		// Tell Terraform that the resource has been successfully destroyed
-		d.SetId("")
+// Fetches the service profile identified by dn and tells whether it is ready.
+// Returns an error describing the faults and configuration issues UCS
+// reports if the profile could not be configured or associated.
+func serviceProfileStateRefreshFunc(client *ucsclient.UCSClient, dn string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		sp, err := client.ConfigResolveDN(dn)
+		if err != nil {
+			return nil, "", err
+		}
 
-		client.Logger.Debug("Exiting resourceUcsServiceProfileDelete(...)\n")
-		return nil
-	})
+		if sp == nil {
+			return nil, "", fmt.Errorf("Service profile %s no longer exists", dn)
+		}
+
+		if sp.Failed() {
+			return sp, "", fmt.Errorf("Service profile %s could not be associated: %s", dn, describeServiceProfile(sp))
+		}
+
+		if sp.Ready() {
+			return sp, SP_STATE_READY, nil
+		}
+
+		client.Logger.Debug("Service profile %s is not ready yet: %s\n", dn, describeServiceProfile(sp))
+		return sp, SP_STATE_PENDING, nil
+	}
+}
+
+// Summarises the state, configuration issues and faults of a service profile.
+func describeServiceProfile(sp *ucsclient.ServiceProfile) string {
+	desc := fmt.Sprintf("assocState=%s, configState=%s, operState=%s", sp.AssocState, sp.ConfigState, sp.OperState)
+	if sp.FsmError != "" {
+		desc += ", fsm error: " + sp.FsmError
+	}
+
+	if len(sp.ConfigIssues) > 0 {
+		areas := make([]string, 0, len(sp.ConfigIssues))
+		for area := range sp.ConfigIssues {
+			areas = append(areas, area)
+		}
+		sort.Strings(areas)
+
+		issues := make([]string, 0, len(areas))
+		for _, area := range areas {
+			issues = append(issues, area+": "+sp.ConfigIssues[area])
+		}
+		desc += "; config issues: " + strings.Join(issues, ", ")
+	}
+
+	if len(sp.Faults) > 0 {
+		faults := make([]string, 0, len(sp.Faults))
+		for _, f := range sp.Faults {
+			faults = append(faults, fmt.Sprintf("%s (%s) %s", f.Code, f.Severity, f.Descr))
+		}
+		desc += "; faults: " + strings.Join(faults, "; ")
+	}
+	return desc
+}
+
+var powerStates = []string{
+	ucsclient.POWER_STATE_UP,
+	ucsclient.POWER_STATE_DOWN,
+	ucsclient.POWER_STATE_SOFT_SHUT_DOWN,
+	ucsclient.POWER_STATE_CYCLE_IMMEDIATE,
+	ucsclient.POWER_STATE_HARD_RESET_IMMEDIATE,
+}
+
+func validatePowerState(v interface{}, k string) (ws []string, errors []error) {
+	state := v.(string)
+	for _, s := range powerStates {
+		if s == state {
+			return
+		}
+	}
+	errors = append(errors, fmt.Errorf("%q must be one of %s; got %q", k, strings.Join(powerStates, ", "), state))
+	return
+}
+
+// Returns the steady power state a server ends up in after the given one
+// has been requested.
+func steadyPowerState(state string) string {
+	switch state {
+	case ucsclient.POWER_STATE_CYCLE_IMMEDIATE, ucsclient.POWER_STATE_HARD_RESET_IMMEDIATE:
+		return ucsclient.POWER_STATE_UP
+	case ucsclient.POWER_STATE_SOFT_SHUT_DOWN:
+		return ucsclient.POWER_STATE_DOWN
+	}
+	return state
+}
+
+// Determines whether the requested power state is satisfied by the one
+// reported by UCS.
+func powerStatesEquivalent(requested, reported string) bool {
+	return requested != "" && (requested == reported || steadyPowerState(requested) == steadyPowerState(reported))
+}
+
+// Severities of UCS faults from the least to the most severe.
+var faultSeverities = []string{"cleared", "info", "condition", "warning", "minor", "major", "critical"}
+
+func faultSeverityRank(severity string) int {
+	for i, s := range faultSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+func validateFaultSeverity(v interface{}, k string) (ws []string, errors []error) {
+	severity := v.(string)
+	if faultSeverityRank(severity) < faultSeverityRank("warning") {
+		errors = append(errors, fmt.Errorf("%q must be one of warning, minor, major or critical; got %q", k, severity))
+	}
+	return
+}
+
+// Returns the faults whose severity is the given one or higher.
+func faultsWithSeverity(faults []ucsclient.Fault, severity string) []ucsclient.Fault {
+	min := faultSeverityRank(severity)
+	ret := make([]ucsclient.Fault, 0)
+	for _, f := range faults {
+		if faultSeverityRank(f.Severity) >= min {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+func flattenFaults(faults []ucsclient.Fault) []map[string]string {
+	ret := make([]map[string]string, 0, len(faults))
+	for _, f := range faults {
+		ret = append(ret, map[string]string{
+			"code":     f.Code,
+			"severity": f.Severity,
+			"cause":    f.Cause,
+			"descr":    f.Descr,
+			"created":  f.Created,
+		})
+	}
+	return ret
+}
+
+func fetchVnicsFromResourceData(d *schema.ResourceData) []ucsclient.VNIC {
+	return expandVnics(d.Get("vNIC").([]interface{}))
 }
 
-func fetchVnicsFromResourceData(d *schema.ResourceData) (ret []ucsclient.VNIC) {
-	vnics := d.Get("vNIC").([]interface{})
+func expandVnics(vnics []interface{}) (ret []ucsclient.VNIC) {
 	for _, item := range vnics {
 		vnic := item.(map[string]interface{})
 		ret = append(ret, ucsclient.VNIC{
-			Name: vnic["name"].(string),
-			Ip:   net.ParseIP(vnic["ip"].(string)),
-			CIDR: vnic["cidr"].(string),
+			Name:           vnic["name"].(string),
+			Mac:            stringOrEmpty(vnic["mac"]),
+			Ip:             net.ParseIP(stringOrEmpty(vnic["ip"])),
+			CIDR:           stringOrEmpty(vnic["cidr"]),
+			Exclude:        expandStrings(vnic["exclude"]),
+			RangeStart:     stringOrEmpty(vnic["range_start"]),
+			RangeEnd:       stringOrEmpty(vnic["range_end"]),
+			IPv6:           net.ParseIP(stringOrEmpty(vnic["ipv6"])),
+			IPv6CIDR:       stringOrEmpty(vnic["ipv6_cidr"]),
+			IPv6Allocation: stringOrEmpty(vnic["ipv6_allocation"]),
 		})
 	}
 	return
 }
 
-func mergeVnics(localVnics, remoteVnics []ucsclient.VNIC) (ret []map[string]string) {
+func flattenVnic(vnic ucsclient.VNIC) map[string]interface{} {
+	m := map[string]interface{}{
+		"name":            vnic.Name,
+		"mac":             vnic.Mac,
+		"cidr":            vnic.CIDR,
+		"ip":              ipOrEmpty(vnic.Ip),
+		"exclude":         vnic.Exclude,
+		"range_start":     vnic.RangeStart,
+		"range_end":       vnic.RangeEnd,
+		"ipv6_cidr":       vnic.IPv6CIDR,
+		"ipv6_allocation": vnic.IPv6Allocation,
+		"ipv6":            ipOrEmpty(vnic.IPv6),
+		"gateway":         ipOrEmpty(vnic.Gateway),
+		"dns_servers":     ipsToStrings(vnic.DNSServers),
+		"domain":          vnic.Domain,
+	}
+
+	if _, network, err := net.ParseCIDR(vnic.CIDR); err == nil {
+		m["netmask"] = net.IP(network.Mask).String()
+		m["prefix_length"], _ = network.Mask.Size()
+	}
+	return m
+}
+
+func ipsToStrings(ips []net.IP) []string {
+	ret := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		ret = append(ret, ip.String())
+	}
+	return ret
+}
+
+func expandStrings(v interface{}) []string {
+	items, _ := v.([]interface{})
+	ret := make([]string, 0, len(items))
+	for _, item := range items {
+		ret = append(ret, stringOrEmpty(item))
+	}
+	return ret
+}
+
+func stringOrEmpty(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func ipOrEmpty(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// Returns the pool the IPs of the vNIC called name are allocated from.
+// Each vNIC name has its own pool, whose network is the given CIDR.
+func vnicPool(name, cidr string) (*ipman.Pool, error) {
+	pool := &ipman.Pool{Name: name}
+	if cidr != "" {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		pool.Network = network
+	}
+	return pool, nil
+}
+
+// Copies the gateway, DNS servers and domain of the ip_pool of the provider
+// named after the vNIC, if any, into the vNIC. The pool must have the same
+// network as the vNIC's cidr. Its range and exclusions are applied when
+// allocating the vNIC's ip, see vnicIPPool.
+func applyPoolSettings(pools map[string]*ipman.Pool, vnic *ucsclient.VNIC) error {
+	pool, ok := pools[vnic.Name]
+	if !ok || vnic.CIDR == "" {
+		return nil
+	}
+
+	_, network, err := net.ParseCIDR(vnic.CIDR)
+	if err != nil {
+		return err
+	}
+
+	if network.String() != pool.Network.String() {
+		return fmt.Errorf("The cidr of vNIC %s (%s) does not match the one of ip_pool %s (%s)", vnic.Name, network, pool.Name, pool.Network)
+	}
+
+	vnic.Gateway = pool.Gateway
+	vnic.DNSServers = pool.DNSServers
+	vnic.Domain = pool.Domain
+	return nil
+}
+
+// Returns the pool the ip of the vNIC is allocated from, honouring its
+// exclusions, range and gateway along with the ones of the ip_pool named
+// after it, if any: both share the same inventory, so the addresses handed
+// out to the vNIC must follow the rules of the ip_pool too.
+func vnicIPPool(pools map[string]*ipman.Pool, vnic ucsclient.VNIC) (*ipman.Pool, error) {
+	pool, err := vnicPool(vnic.Name, vnic.CIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	pool.Gateway = vnic.Gateway
+	pool.Start = net.ParseIP(vnic.RangeStart)
+	pool.End = net.ParseIP(vnic.RangeEnd)
+	pool.Reserved, pool.Excluded, err = parseExclusions(vnic.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	if shared, ok := pools[vnic.Name]; ok && vnic.CIDR != "" {
+		if shared.Start != nil && (pool.Start == nil || bytes.Compare(shared.Start.To16(), pool.Start.To16()) > 0) {
+			pool.Start = shared.Start
+		}
+		if shared.End != nil && (pool.End == nil || bytes.Compare(shared.End.To16(), pool.End.To16()) < 0) {
+			pool.End = shared.End
+		}
+		pool.Reserved = append(pool.Reserved, shared.Reserved...)
+		pool.Excluded = append(pool.Excluded, shared.Excluded...)
+	}
+	return pool, nil
+}
+
+// Returns the pool the ipv6 address of the vNIC is allocated from,
+// honouring its exclusions.
+func vnicIPv6Pool(vnic ucsclient.VNIC) (*ipman.Pool, error) {
+	pool, err := vnicPool(vnic.Name, vnic.IPv6CIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	pool.Reserved, pool.Excluded, err = parseExclusions(vnic.Exclude)
+	return pool, err
+}
+
+// Splits the exclusions of a vNIC into single IPs and networks.
+func parseExclusions(exclusions []string) (ips []net.IP, networks []*net.IPNet, err error) {
+	for _, exclusion := range exclusions {
+		if strings.Contains(exclusion, "/") {
+			_, network, err := net.ParseCIDR(exclusion)
+			if err != nil {
+				return nil, nil, err
+			}
+			networks = append(networks, network)
+			continue
+		}
+
+		ip := net.ParseIP(exclusion)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("Invalid exclusion %q: expected an IP or a CIDR", exclusion)
+		}
+		ips = append(ips, ip)
+	}
+	return
+}
+
+// Records the ip pinned in the configuration of the vNIC, if any, as
+// allocated to it. Fails if the IP does not belong to the vNIC's cidr or is
+// allocated to someone else.
+func claimVnicIP(allocator ipman.Allocator, pools map[string]*ipman.Pool, dn string, vnic ucsclient.VNIC) error {
+	if vnic.Ip == nil {
+		return nil
+	}
+
+	pool, err := vnicIPPool(pools, vnic)
+	if err != nil {
+		return err
+	}
+	return allocator.Claim(pool, vnic.Ip, dn+"/ether-"+vnic.Name)
+}
+
+// Assigns an IP (and an IPv6 address for dual-stack vNICs) to each of the
+// vNICs of the service profile identified by dn which do not have one yet.
+// Each IP is recorded as owned by the vNIC's DN. If any of them can't be
+// assigned, the ones assigned so far are released: they would never make it
+// into the state, so nothing else would ever release them.
+func assignVnicIPs(allocator ipman.Allocator, pools map[string]*ipman.Pool, dn string, vnics []ucsclient.VNIC) (ret []map[string]interface{}, err error) {
+	var assignedPools []*ipman.Pool
+	var assigned []net.IP
+	defer func() {
+		if err != nil {
+			for i := range assigned {
+				allocator.Release(assignedPools[i], assigned[i])
+			}
+		}
+	}()
+
+	ret = make([]map[string]interface{}, len(vnics))
+	for i, vnic := range vnics {
+		owner := dn + "/ether-" + vnic.Name
+
+		if vnic.Ip == nil {
+			pool, err := vnicIPPool(pools, vnic)
+			if err != nil {
+				return nil, err
+			}
+
+			// IPv6-only vNICs pick their address the way ipv6_cidr does.
+			if isIPv6CIDR(vnic.CIDR) {
+				vnic.Ip, err = allocateIPv6(allocator, pool, vnic, owner)
+			} else {
+				vnic.Ip, err = allocator.Allocate(pool, owner)
+			}
+			if err != nil {
+				return nil, err
+			}
+			assignedPools, assigned = append(assignedPools, pool), append(assigned, vnic.Ip)
+		}
+
+		if vnic.IPv6 == nil && vnic.IPv6CIDR != "" {
+			pool, err := vnicIPv6Pool(vnic)
+			if err != nil {
+				return nil, err
+			}
+
+			vnic.IPv6, err = allocateIPv6(allocator, pool, vnic, owner)
+			if err != nil {
+				return nil, err
+			}
+			assignedPools, assigned = append(assignedPools, pool), append(assigned, vnic.IPv6)
+		}
+
+		ret[i] = flattenVnic(vnic)
+	}
+	return ret, nil
+}
+
+// Picks the IPv6 address of the vNIC from pool, whose network is either the
+// vNIC's ipv6_cidr or its cidr if that is an IPv6 one: either derived from
+// its MAC (EUI-64) or the next free one, as set by ipv6_allocation.
+func allocateIPv6(allocator ipman.Allocator, pool *ipman.Pool, vnic ucsclient.VNIC, owner string) (net.IP, error) {
+	if vnic.IPv6Allocation == IPV6_ALLOCATION_SEQUENTIAL {
+		return allocator.Allocate(pool, owner)
+	}
+
+	mac, err := net.ParseMAC(vnic.Mac)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot derive the IPv6 address of vNIC %s from its MAC %q: %s", vnic.Name, vnic.Mac, err)
+	}
+
+	ip, err := ipman.EUI64(pool.Network, mac)
+	if err != nil {
+		return nil, err
+	}
+	return ip, allocator.Claim(pool, ip, owner)
+}
+
+// Returns the IPs of the vNIC to their pools.
+func releaseVnicIPs(allocator ipman.Allocator, vnic ucsclient.VNIC) error {
+	err := releaseIP(allocator, vnic.Name, vnic.CIDR, vnic.Ip)
+	if err != nil {
+		return err
+	}
+	return releaseIP(allocator, vnic.Name, vnic.IPv6CIDR, vnic.IPv6)
+}
+
+// Records the IPs of the vNICs as owned by the vNICs of the service profile
+// identified by newDn rather than oldDn, e.g. once the profile was renamed,
+// by releasing them and claiming them again for the new owners. IPs owned
+// by anyone else are left alone.
+func moveVnicIPs(allocator ipman.Allocator, oldDn, newDn string, vnics []ucsclient.VNIC) error {
+	for _, vnic := range vnics {
+		oldOwner, owner := oldDn+"/ether-"+vnic.Name, newDn+"/ether-"+vnic.Name
+		for cidr, ip := range map[string]net.IP{vnic.CIDR: vnic.Ip, vnic.IPv6CIDR: vnic.IPv6} {
+			if cidr == "" || ip == nil {
+				continue
+			}
+
+			pool, err := vnicPool(vnic.Name, cidr)
+			if err != nil {
+				return err
+			}
+
+			a, err := allocator.Lookup(pool, ip)
+			if err != nil {
+				return err
+			}
+
+			if a == nil || a.Owner != oldOwner {
+				continue
+			}
+
+			err = allocator.Release(pool, ip)
+			if err != nil {
+				return err
+			}
+
+			err = allocator.Claim(pool, ip, owner)
+			if err != nil {
+				allocator.Claim(pool, ip, oldOwner)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Prepares the vNICs of the service profile identified by dn, whose
+// previous state is in oldVnics, for being readdressed. IPs pinned to a new
+// value are claimed. When the cidr changes, an IP the new cidr contains is
+// claimed as well, which checks it against the new pool; one it does not
+// contain is left from the previous cidr and cleared so that a new one is
+// allocated, as are the IPs of an IPv6 cidr whose allocation changes. IPv6
+// addresses are cleared when their cidr or allocation change. Returns the vNICs whose IPs were claimed, to be released if the
+// change fails, and the old IPs which are replaced, to be released once the
+// new ones have been assigned. Nothing is released here: on error, the
+// IPs claimed so far are given back and the old ones are left alone.
+func readdressVnics(allocator ipman.Allocator, pools map[string]*ipman.Pool, dn string, oldVnics map[string]ucsclient.VNIC, vnics []ucsclient.VNIC) (claimed, stale []ucsclient.VNIC, err error) {
+	defer func() {
+		if err != nil {
+			releaseClaimedIPs(allocator, claimed)
+			claimed, stale = nil, nil
+		}
+	}()
+
+	for i := range vnics {
+		err = validateCIDR(vnics[i].CIDR)
+		if err != nil {
+			return
+		}
+
+		err = applyPoolSettings(pools, &vnics[i])
+		if err != nil {
+			return
+		}
+
+		oldVnic, ok := oldVnics[vnics[i].Name]
+		if !ok {
+			continue
+		}
+
+		// The ipv6_allocation applies to an IPv6 cidr as well.
+		pinned := vnics[i].Ip != nil && !vnics[i].Ip.Equal(oldVnic.Ip)
+		cidrChanged := vnics[i].CIDR != oldVnic.CIDR
+		allocationChanged := isIPv6CIDR(vnics[i].CIDR) && vnics[i].IPv6Allocation != oldVnic.IPv6Allocation
+		if pinned || cidrChanged || allocationChanged {
+			if pinned || (!allocationChanged && cidrContains(vnics[i].CIDR, vnics[i].Ip)) {
+				err = claimVnicIP(allocator, pools, dn, vnics[i])
+				if err != nil {
+					return
+				}
+
+				if pinned {
+					claimed = append(claimed, vnics[i])
+				}
+			} else {
+				vnics[i].Ip = nil
+			}
+
+			if oldVnic.Ip != nil && !oldVnic.Ip.Equal(vnics[i].Ip) {
+				stale = append(stale, ucsclient.VNIC{Name: oldVnic.Name, CIDR: oldVnic.CIDR, Ip: oldVnic.Ip})
+			}
+		}
+
+		if vnics[i].IPv6CIDR != oldVnic.IPv6CIDR || vnics[i].IPv6Allocation != oldVnic.IPv6Allocation {
+			if oldVnic.IPv6 != nil {
+				stale = append(stale, ucsclient.VNIC{Name: oldVnic.Name, IPv6CIDR: oldVnic.IPv6CIDR, IPv6: oldVnic.IPv6})
+			}
+			vnics[i].IPv6 = nil
+		}
+	}
+	return
+}
+
+// Determines whether cidr is an IPv6 network. Invalid CIDRs are neither.
+func isIPv6CIDR(cidr string) bool {
+	_, network, err := net.ParseCIDR(cidr)
+	return err == nil && network.IP.To4() == nil
+}
+
+// Determines whether ip belongs to cidr. Invalid CIDRs contain nothing.
+func cidrContains(cidr string, ip net.IP) bool {
+	_, network, err := net.ParseCIDR(cidr)
+	return err == nil && ip != nil && network.Contains(ip)
+}
+
+// Gives back the IPs pinned in the given vNICs and claimed by claimVnicIP
+// for a change which failed. Errors are ignored, as the failure is what has
+// to be reported.
+func releaseClaimedIPs(allocator ipman.Allocator, vnics []ucsclient.VNIC) {
+	for _, vnic := range vnics {
+		releaseIP(allocator, vnic.Name, vnic.CIDR, vnic.Ip)
+	}
+}
+
+// Returns ip, if any, to the pool of the vNIC called name.
+func releaseIP(allocator ipman.Allocator, name, cidr string, ip net.IP) error {
+	if ip == nil {
+		return nil
+	}
+
+	pool, err := vnicPool(name, cidr)
+	if err != nil {
+		return err
+	}
+	return allocator.Release(pool, ip)
+}
+
+// Returns the vNICs known locally along with the MACs UCS reports for them.
+func mergeVnics(localVnics, remoteVnics []ucsclient.VNIC) (ret []map[string]interface{}) {
 	for _, local := range localVnics {
 		for _, remote := range remoteVnics {
 			if local.Name == remote.Name {
-				ret = append(ret, map[string]string{
-					"name": remote.Name,
-					"ip":   local.Ip.String(),
-					"mac":  remote.Mac,
-					"cidr": local.CIDR,
-				})
+				local.Mac = remote.Mac
+				ret = append(ret, flattenVnic(local))
 				break
 			}
 		}
@@ -274,16 +1177,28 @@ func validateCIDR(cidr string) (err error) {
 	return
 }
 
-func withSession(c *ucsclient.UCSClient, cb sessionCallback) error {
-	sessionMutex.Lock()
-	defer sessionMutex.Unlock()
-
-	err := c.Login()
+func validateIPv6CIDR(v interface{}, k string) (ws []string, errors []error) {
+	ip, _, err := net.ParseCIDR(v.(string))
 	if err != nil {
-		return err
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	} else if ip.To4() != nil {
+		errors = append(errors, fmt.Errorf("%q must be an IPv6 CIDR; got %q", k, v))
 	}
+	return
+}
 
-	cb(c)
-	c.Logout()
-	return nil
+func validateIPv6Allocation(v interface{}, k string) (ws []string, errors []error) {
+	switch v.(string) {
+	case IPV6_ALLOCATION_EUI64, IPV6_ALLOCATION_SEQUENTIAL:
+	default:
+		errors = append(errors, fmt.Errorf("%q must be either %s or %s; got %q", k, IPV6_ALLOCATION_EUI64, IPV6_ALLOCATION_SEQUENTIAL, v))
+	}
+	return
+}
+
+func validateIP(v interface{}, k string) (ws []string, errors []error) {
+	if net.ParseIP(v.(string)) == nil {
+		errors = append(errors, fmt.Errorf("%q must be an IP address; got %q", k, v))
+	}
+	return
 }
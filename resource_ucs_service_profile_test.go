@@ -1,7 +1,13 @@
 package main
 
 import (
+	"io/ioutil"
+	"net"
+	"os"
 	"testing"
+
+	"github.com/CiscoUcs/UCS-Terraform/ipman"
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
 )
 
 func TestValidateCIDR(t *testing.T) {
@@ -17,3 +23,636 @@ func TestValidateCIDR(t *testing.T) {
 		t.Errorf(`Error expected but got nil with cidr = "%s"`, cidr)
 	}
 }
+
+func TestDescribeServiceProfile(t *testing.T) {
+	sp := &ucsclient.ServiceProfile{
+		AssocState:  "unassociated",
+		ConfigState: "failed-to-apply",
+		OperState:   "config-failure",
+		ConfigIssues: map[string]string{
+			"vnic":   "wwpn-derivation-virtualized-port",
+			"server": "insufficient-resources",
+		},
+		Faults: []ucsclient.Fault{
+			{Code: "F0327", Severity: "major", Descr: "Service profile foobar configuration failed"},
+		},
+	}
+	expected := "assocState=unassociated, configState=failed-to-apply, operState=config-failure; " +
+		"config issues: server: insufficient-resources, vnic: wwpn-derivation-virtualized-port; " +
+		"faults: F0327 (major) Service profile foobar configuration failed"
+
+	actual := describeServiceProfile(sp)
+	if actual != expected {
+		t.Errorf("%s expected; got %s", expected, actual)
+	}
+}
+
+func TestValidateFaultSeverity(t *testing.T) {
+	for _, severity := range []string{"warning", "minor", "major", "critical"} {
+		_, errs := validateFaultSeverity(severity, "fail_on_fault_severity")
+		if len(errs) > 0 {
+			t.Errorf("no errors expected for %s; got %v", severity, errs)
+		}
+	}
+
+	for _, severity := range []string{"info", "cleared", "catastrophic", ""} {
+		_, errs := validateFaultSeverity(severity, "fail_on_fault_severity")
+		if len(errs) == 0 {
+			t.Errorf("error expected for %q; got nil", severity)
+		}
+	}
+}
+
+func TestFaultsWithSeverity(t *testing.T) {
+	faults := []ucsclient.Fault{
+		{Code: "F4525248", Severity: "warning"},
+		{Code: "F0327", Severity: "major"},
+		{Code: "F0170", Severity: "minor"},
+		{Code: "F0999", Severity: "critical"},
+	}
+
+	actual := faultsWithSeverity(faults, "major")
+	if len(actual) != 2 || actual[0].Code != "F0327" || actual[1].Code != "F0999" {
+		t.Errorf("F0327 and F0999 expected; got %+v", actual)
+	}
+
+	actual = faultsWithSeverity(faults, "warning")
+	if len(actual) != len(faults) {
+		t.Errorf("%d faults expected; got %d", len(faults), len(actual))
+	}
+}
+
+func TestValidatePowerState(t *testing.T) {
+	for _, state := range []string{"up", "down", "soft-shut-down", "cycle-immediate", "hard-reset-immediate"} {
+		_, errs := validatePowerState(state, "power_state")
+		if len(errs) > 0 {
+			t.Errorf("no errors expected for %s; got %v", state, errs)
+		}
+	}
+
+	for _, state := range []string{"off", "UP", ""} {
+		_, errs := validatePowerState(state, "power_state")
+		if len(errs) == 0 {
+			t.Errorf("error expected for %q; got nil", state)
+		}
+	}
+}
+
+func TestPowerStatesEquivalent(t *testing.T) {
+	tests := []struct {
+		Requested string
+		Reported  string
+		Expected  bool
+	}{
+		{"up", "up", true},
+		{"down", "up", false},
+		{"cycle-immediate", "up", true},
+		{"hard-reset-immediate", "up", true},
+		{"cycle-immediate", "down", false},
+		{"soft-shut-down", "down", true},
+		{"soft-shut-down", "soft-shut-down", true},
+		{"", "up", false},
+	}
+
+	for _, test := range tests {
+		actual := powerStatesEquivalent(test.Requested, test.Reported)
+		if actual != test.Expected {
+			t.Errorf("powerStatesEquivalent(%q, %q) = %v expected; got %v", test.Requested, test.Reported, test.Expected, actual)
+		}
+	}
+}
+
+func TestMergeVnicsWithoutIP(t *testing.T) {
+	local := []ucsclient.VNIC{
+		{Name: "eth0"},
+	}
+	remote := []ucsclient.VNIC{
+		{Name: "eth0", Mac: "00:25:B5:00:00:9F"},
+		{Name: "eth1", Mac: "00:25:B5:00:00:8F"},
+	}
+
+	vnics := mergeVnics(local, remote)
+	if len(vnics) != 1 {
+		t.Fatalf("1 vNIC expected; got %d", len(vnics))
+	}
+
+	if vnics[0]["ip"] != "" {
+		t.Errorf("blank IP expected; got %s", vnics[0]["ip"])
+	}
+
+	if mac := "00:25:B5:00:00:9F"; vnics[0]["mac"] != mac {
+		t.Errorf("%s expected; got %s", mac, vnics[0]["mac"])
+	}
+}
+
+func TestAssignVnicIPs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	allocator := ipman.NewFileAllocator(dir)
+	vnics := []ucsclient.VNIC{
+		{
+			Name:           "eth0",
+			Mac:            "00:25:B5:00:00:9F",
+			CIDR:           "10.0.1.0/24",
+			IPv6CIDR:       "2001:db8:1::/64",
+			IPv6Allocation: IPV6_ALLOCATION_EUI64,
+		},
+		{
+			Name:           "eth1",
+			Mac:            "00:25:B5:00:00:8F",
+			CIDR:           "2001:db8:2::/64",
+			IPv6CIDR:       "2001:db8:3::/64",
+			IPv6Allocation: IPV6_ALLOCATION_SEQUENTIAL,
+		},
+		{
+			Name: "eth2",
+			CIDR: "10.0.2.0/24",
+		},
+		{
+			Name:           "eth3",
+			Mac:            "00:25:B5:00:00:7F",
+			CIDR:           "2001:db8:4::/64",
+			IPv6Allocation: IPV6_ALLOCATION_EUI64,
+		},
+	}
+
+	actual, err := assignVnicIPs(allocator, nil, "org-root/ls-foo", vnics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]string{
+		{"ip": "10.0.1.1", "ipv6": "2001:db8:1:0:225:b5ff:fe00:9f"},
+		{"ip": "2001:db8:2::1", "ipv6": "2001:db8:3::1"},
+		{"ip": "10.0.2.1", "ipv6": ""},
+		{"ip": "2001:db8:4:0:225:b5ff:fe00:7f", "ipv6": ""},
+	}
+
+	for i, e := range expected {
+		for key, value := range e {
+			if actual[i][key] != value {
+				t.Errorf("%s of %s: %s expected; got %s", key, vnics[i].Name, value, actual[i][key])
+			}
+		}
+	}
+
+	allocation, err := allocator.Lookup(&ipman.Pool{Name: "eth0"}, net.ParseIP("2001:db8:1:0:225:b5ff:fe00:9f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if allocation == nil || allocation.Owner != "org-root/ls-foo/ether-eth0" {
+		t.Errorf("EUI-64 address expected to be owned by org-root/ls-foo/ether-eth0; got %v", allocation)
+	}
+}
+
+func TestAssignVnicIPsEUI64WithoutMac(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vnics := []ucsclient.VNIC{
+		{
+			Name:           "eth0",
+			CIDR:           "10.0.1.0/24",
+			IPv6CIDR:       "2001:db8:1::/64",
+			IPv6Allocation: IPV6_ALLOCATION_EUI64,
+		},
+	}
+
+	_, err = assignVnicIPs(ipman.NewFileAllocator(dir), nil, "org-root/ls-foo", vnics)
+	if err == nil {
+		t.Errorf("error expected; got nil")
+	}
+}
+
+func TestAssignVnicIPsReleasesOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	allocator := ipman.NewFileAllocator(dir)
+	vnics := []ucsclient.VNIC{
+		{Name: "eth0", CIDR: "10.0.1.0/24"},
+		{Name: "eth1", CIDR: "10.0.2.0/30", Exclude: []string{"10.0.2.1", "10.0.2.2"}},
+	}
+
+	_, err = assignVnicIPs(allocator, nil, "org-root/ls-foo", vnics)
+	if err == nil {
+		t.Fatalf("error expected for an exhausted pool; got nil")
+	}
+
+	allocations, err := allocator.List(&ipman.Pool{Name: "eth0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(allocations) != 0 {
+		t.Errorf("the IP of eth0 expected to be released; got %v", allocations)
+	}
+}
+
+func TestValidateIPv6CIDR(t *testing.T) {
+	_, errs := validateIPv6CIDR("2001:db8::/64", "ipv6_cidr")
+	if len(errs) > 0 {
+		t.Errorf("no errors expected; got %v", errs)
+	}
+
+	for _, cidr := range []string{"10.0.1.0/24", "2001:db8::", "nope"} {
+		_, errs = validateIPv6CIDR(cidr, "ipv6_cidr")
+		if len(errs) == 0 {
+			t.Errorf("error expected for %q; got nil", cidr)
+		}
+	}
+}
+
+func TestValidateIPv6Allocation(t *testing.T) {
+	for _, allocation := range []string{"eui64", "sequential"} {
+		_, errs := validateIPv6Allocation(allocation, "ipv6_allocation")
+		if len(errs) > 0 {
+			t.Errorf("no errors expected for %s; got %v", allocation, errs)
+		}
+	}
+
+	_, errs := validateIPv6Allocation("random", "ipv6_allocation")
+	if len(errs) == 0 {
+		t.Errorf("error expected; got nil")
+	}
+}
+
+func TestAssignVnicIPsWithExclusions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vnics := []ucsclient.VNIC{
+		{
+			Name:       "eth0",
+			CIDR:       "10.0.1.0/24",
+			RangeStart: "10.0.1.11",
+			Exclude:    []string{"10.0.1.11", "10.0.1.12/31"},
+		},
+	}
+
+	actual, err := assignVnicIPs(ipman.NewFileAllocator(dir), nil, "org-root/ls-foo", vnics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "10.0.1.14"; actual[0]["ip"] != expected {
+		t.Errorf("%s expected; got %s", expected, actual[0]["ip"])
+	}
+}
+
+func TestClaimVnicIP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	allocator := ipman.NewFileAllocator(dir)
+	vnic := ucsclient.VNIC{
+		Name: "eth0",
+		CIDR: "10.0.1.0/24",
+		Ip:   net.ParseIP("10.0.1.1"),
+	}
+
+	err = claimVnicIP(allocator, nil, "org-root/ls-foo", vnic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pinned IP is never handed out to someone else.
+	actual, err := assignVnicIPs(allocator, nil, "org-root/ls-bar", []ucsclient.VNIC{{Name: "eth0", CIDR: "10.0.1.0/24"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "10.0.1.2"; actual[0]["ip"] != expected {
+		t.Errorf("%s expected; got %s", expected, actual[0]["ip"])
+	}
+
+	err = claimVnicIP(allocator, nil, "org-root/ls-bar", ucsclient.VNIC{Name: "eth0", CIDR: "10.0.1.0/24", Ip: net.ParseIP("10.0.1.1")})
+	if err == nil {
+		t.Errorf("error expected when pinning an IP allocated to another profile; got nil")
+	}
+
+	err = claimVnicIP(allocator, nil, "org-root/ls-baz", ucsclient.VNIC{Name: "eth0", CIDR: "10.0.1.0/24", Ip: net.ParseIP("10.0.2.1")})
+	if err == nil {
+		t.Errorf("error expected when pinning an IP outside of the cidr; got nil")
+	}
+}
+
+func TestClaimVnicIPOutsideOfPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, network, _ := net.ParseCIDR("10.0.1.0/24")
+	pools := map[string]*ipman.Pool{
+		"eth0": &ipman.Pool{
+			Name:     "eth0",
+			Network:  network,
+			Reserved: []net.IP{net.ParseIP("10.0.1.30")},
+		},
+	}
+
+	allocator := ipman.NewFileAllocator(dir)
+	tests := map[string]string{
+		"10.0.1.0":   "network address",
+		"10.0.1.255": "broadcast address",
+		"10.0.1.1":   "gateway",
+		"10.0.1.5":   "IP before range_start",
+		"10.0.1.201": "IP after range_end",
+		"10.0.1.20":  "excluded IP",
+		"10.0.1.30":  "IP reserved by the ip_pool",
+	}
+
+	for ip, what := range tests {
+		vnic := ucsclient.VNIC{
+			Name:       "eth0",
+			CIDR:       "10.0.1.0/24",
+			Ip:         net.ParseIP(ip),
+			Gateway:    net.ParseIP("10.0.1.1"),
+			RangeStart: "10.0.1.10",
+			RangeEnd:   "10.0.1.200",
+			Exclude:    []string{"10.0.1.16/29"},
+		}
+
+		if err := claimVnicIP(allocator, pools, "org-root/ls-foo", vnic); err == nil {
+			t.Errorf("error expected when pinning the %s %s; got nil", what, ip)
+		}
+	}
+}
+
+func TestReaddressVnics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	allocator := ipman.NewFileAllocator(dir)
+	old := ucsclient.VNIC{Name: "eth0", CIDR: "10.0.1.0/24", Ip: net.ParseIP("10.0.1.5")}
+	err = claimVnicIP(allocator, nil, "org-root/ls-foo", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldVnics := map[string]ucsclient.VNIC{"eth0": old}
+
+	owned := func(ip string) bool {
+		pool, err := vnicPool("eth0", "10.0.0.0/8")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a, err := allocator.Lookup(pool, net.ParseIP(ip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a != nil && a.Owner == "org-root/ls-foo/ether-eth0"
+	}
+
+	// The pinned IP is checked against the new cidr before anything is
+	// released.
+	rejected := [][]ucsclient.VNIC{
+		{{Name: "eth0", CIDR: "10.0.1.0/24", Ip: net.ParseIP("10.0.1.7"), Exclude: []string{"10.0.1.7"}}},
+		{{Name: "eth0", CIDR: "10.0.0.0/16", Ip: net.ParseIP("10.0.1.5"), RangeStart: "10.0.2.0"}},
+	}
+	for _, vnics := range rejected {
+		_, _, err = readdressVnics(allocator, nil, "org-root/ls-foo", oldVnics, vnics)
+		if err == nil {
+			t.Errorf("error expected for %+v; got nil", vnics[0])
+		}
+
+		if !owned("10.0.1.5") {
+			t.Errorf("10.0.1.5 expected to be kept for %+v", vnics[0])
+		}
+	}
+
+	// The same IP in a wider cidr is kept.
+	vnics := []ucsclient.VNIC{{Name: "eth0", CIDR: "10.0.0.0/16", Ip: net.ParseIP("10.0.1.5")}}
+	claimed, stale, err := readdressVnics(allocator, nil, "org-root/ls-foo", oldVnics, vnics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != 0 || len(stale) != 0 || !vnics[0].Ip.Equal(old.Ip) {
+		t.Errorf("10.0.1.5 expected to be kept; got %v, claimed %v, stale %v", vnics[0].Ip, claimed, stale)
+	}
+
+	// An IP the new cidr does not contain was left from the old one.
+	vnics = []ucsclient.VNIC{{Name: "eth0", CIDR: "10.0.2.0/24", Ip: net.ParseIP("10.0.1.5")}}
+	claimed, stale, err = readdressVnics(allocator, nil, "org-root/ls-foo", oldVnics, vnics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vnics[0].Ip != nil || len(claimed) != 0 || len(stale) != 1 || !stale[0].Ip.Equal(old.Ip) {
+		t.Errorf("10.0.1.5 expected to be stale; got %v, claimed %v, stale %v", vnics[0].Ip, claimed, stale)
+	}
+
+	// A new pinned IP is claimed and the old one is only stale.
+	vnics = []ucsclient.VNIC{{Name: "eth0", CIDR: "10.0.1.0/24", Ip: net.ParseIP("10.0.1.6")}}
+	claimed, stale, err = readdressVnics(allocator, nil, "org-root/ls-foo", oldVnics, vnics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != 1 || len(stale) != 1 || !owned("10.0.1.6") || !owned("10.0.1.5") {
+		t.Errorf("10.0.1.6 expected to be claimed and 10.0.1.5 stale; got claimed %v, stale %v", claimed, stale)
+	}
+}
+
+func TestReaddressVnicsIPv6Allocation(t *testing.T) {
+	old := ucsclient.VNIC{Name: "eth0", CIDR: "2001:db8::/64", Ip: net.ParseIP("2001:db8::1"), IPv6Allocation: IPV6_ALLOCATION_SEQUENTIAL}
+	vnics := []ucsclient.VNIC{{Name: "eth0", CIDR: "2001:db8::/64", Ip: net.ParseIP("2001:db8::1"), IPv6Allocation: IPV6_ALLOCATION_EUI64}}
+
+	_, stale, err := readdressVnics(nil, nil, "org-root/ls-foo", map[string]ucsclient.VNIC{"eth0": old}, vnics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vnics[0].Ip != nil || len(stale) != 1 || !stale[0].Ip.Equal(old.Ip) {
+		t.Errorf("2001:db8::1 expected to be stale; got %v, stale %v", vnics[0].Ip, stale)
+	}
+}
+
+func TestMoveVnicIPs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	allocator := ipman.NewFileAllocator(dir)
+	vnics := []ucsclient.VNIC{{Name: "eth0", CIDR: "10.0.1.0/24", IPv6CIDR: "2001:db8::/64", IPv6Allocation: IPV6_ALLOCATION_SEQUENTIAL}}
+	assigned, err := assignVnicIPs(allocator, nil, "org-root/ls-foo", vnics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// org-root/ls-foo is renamed to org-root/ls-bar.
+	vnics = expandVnics([]interface{}{map[string]interface{}(assigned[0])})
+	err = moveVnicIPs(allocator, "org-root/ls-foo", "org-root/ls-bar", vnics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for cidr, ip := range map[string]net.IP{vnics[0].CIDR: vnics[0].Ip, vnics[0].IPv6CIDR: vnics[0].IPv6} {
+		pool, err := vnicPool("eth0", cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		live := []ipman.Allocation{{IP: ip, Owner: "org-root/ls-bar/ether-eth0"}}
+		report, err := ipman.Reconcile(allocator, pool, live, isVnicDn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !report.Clean() {
+			t.Errorf("clean report expected for %s; got %+v", cidr, report)
+		}
+	}
+}
+
+func TestParseExclusions(t *testing.T) {
+	ips, networks, err := parseExclusions([]string{"10.0.1.1", "10.0.1.0/28", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ips) != 2 || len(networks) != 1 {
+		t.Errorf("2 IPs and 1 network expected; got %v and %v", ips, networks)
+	}
+
+	_, _, err = parseExclusions([]string{"10.0.1.300"})
+	if err == nil {
+		t.Errorf("error expected; got nil")
+	}
+}
+
+func TestApplyPoolSettings(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.1.0/24")
+	pools := map[string]*ipman.Pool{
+		"eth0": &ipman.Pool{
+			Name:       "eth0",
+			Network:    network,
+			Gateway:    net.ParseIP("10.0.1.1"),
+			DNSServers: []net.IP{net.ParseIP("10.0.0.53")},
+			Domain:     "example.com",
+		},
+	}
+
+	vnic := ucsclient.VNIC{Name: "eth0", CIDR: "10.0.1.0/24"}
+	if err := applyPoolSettings(pools, &vnic); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	actual, err := assignVnicIPs(ipman.NewFileAllocator(dir), nil, "org-root/ls-foo", []ucsclient.VNIC{vnic})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The gateway is never allocated.
+	expected := map[string]interface{}{
+		"ip":            "10.0.1.2",
+		"netmask":       "255.255.255.0",
+		"prefix_length": 24,
+		"gateway":       "10.0.1.1",
+		"domain":        "example.com",
+	}
+	for k, v := range expected {
+		if actual[0][k] != v {
+			t.Errorf("%v expected for %s; got %v", v, k, actual[0][k])
+		}
+	}
+
+	if servers := actual[0]["dns_servers"].([]string); len(servers) != 1 || servers[0] != "10.0.0.53" {
+		t.Errorf("[10.0.0.53] expected; got %v", servers)
+	}
+
+	vnic = ucsclient.VNIC{Name: "eth0", CIDR: "10.0.2.0/24"}
+	if err := applyPoolSettings(pools, &vnic); err == nil {
+		t.Errorf("error expected for a cidr not matching the pool; got nil")
+	}
+
+	vnic = ucsclient.VNIC{Name: "eth1", CIDR: "10.0.2.0/24"}
+	if err := applyPoolSettings(pools, &vnic); err != nil || vnic.Gateway != nil {
+		t.Errorf("vNIC without a pool expected to be left alone; got %v, %v", vnic, err)
+	}
+}
+
+func TestAssignVnicIPsHonoursIPPool(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.1.0/24")
+	_, excluded, _ := net.ParseCIDR("10.0.1.12/31")
+	pools := map[string]*ipman.Pool{
+		"eth0": &ipman.Pool{
+			Name:     "eth0",
+			Network:  network,
+			Start:    net.ParseIP("10.0.1.10"),
+			End:      net.ParseIP("10.0.1.20"),
+			Reserved: []net.IP{net.ParseIP("10.0.1.10"), net.ParseIP("10.0.1.11")},
+			Excluded: []*net.IPNet{excluded},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "ucs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The vNIC's own exclusions and range add to the ones of the ip_pool.
+	vnic := ucsclient.VNIC{Name: "eth0", CIDR: "10.0.1.0/24", RangeStart: "10.0.1.5", Exclude: []string{"10.0.1.14"}}
+	actual, err := assignVnicIPs(ipman.NewFileAllocator(dir), pools, "org-root/ls-foo", []ucsclient.VNIC{vnic})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "10.0.1.15"; actual[0]["ip"] != expected {
+		t.Errorf("%s expected; got %s", expected, actual[0]["ip"])
+	}
+
+	pool, err := vnicIPPool(pools, ucsclient.VNIC{Name: "eth0", CIDR: "10.0.1.0/24", RangeEnd: "10.0.1.30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "10.0.1.20"; pool.End.String() != expected {
+		t.Errorf("%s expected; got %s", expected, pool.End)
+	}
+}
+
+func TestSplitServiceProfileDn(t *testing.T) {
+	org, name := splitServiceProfileDn("org-root/org-a/ls-foo")
+	if org != "org-root/org-a" || name != "foo" {
+		t.Errorf("%s, %s expected; got %s, %s", "org-root/org-a", "foo", org, name)
+	}
+
+	for _, dn := range []string{"ls-foo", "org-root", "org-root/ls-", "org-root/mac-pool-foo", "foo/ls-bar"} {
+		if org, _ := splitServiceProfileDn(dn); org != "" {
+			t.Errorf("blank organization expected for %s; got %s", dn, org)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Fabric interconnects a VLAN (or VSAN) can be defined on. Dual ones are
+// shared by both.
+const (
+	FABRIC_A    = "A"
+	FABRIC_B    = "B"
+	FABRIC_DUAL = "dual"
+)
+
+// Private VLAN types of a VLAN. Isolated and community VLANs belong to a
+// primary one.
+const (
+	VLAN_SHARING_NONE      = "none"
+	VLAN_SHARING_PRIMARY   = "primary"
+	VLAN_SHARING_ISOLATED  = "isolated"
+	VLAN_SHARING_COMMUNITY = "community"
+)
+
+// IDs UCS accepts for VLANs. The ones from VLAN_RESERVED_FIRST to
+// VLAN_RESERVED_LAST are kept for internal use by the fabric interconnects.
+const (
+	VLAN_ID_MIN         = 1
+	VLAN_ID_MAX         = 4093
+	VLAN_RESERVED_FIRST = 3915
+	VLAN_RESERVED_LAST  = 4047
+)
+
+var fabrics = []string{FABRIC_A, FABRIC_B, FABRIC_DUAL}
+
+var vlanSharings = []string{
+	VLAN_SHARING_NONE,
+	VLAN_SHARING_PRIMARY,
+	VLAN_SHARING_ISOLATED,
+	VLAN_SHARING_COMMUNITY,
+}
+
+// A VLAN of the LAN cloud (fabricVlan), e.g. fabric/lan/net-web.
+func resourceUcsVlan() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceUcsVlanCreate,
+		Read:   resourceUcsVlanRead,
+		Update: resourceUcsVlanUpdate,
+		Delete: resourceUcsVlanDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceUcsVlanImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"vlan_id": &schema.Schema{
+				Type:         schema.TypeInt,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateVlanID,
+			},
+			"fabric": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      FABRIC_DUAL,
+				ForceNew:     true,
+				ValidateFunc: validateFabric,
+				Description:  "Fabric interconnect the VLAN is defined on: A, B or dual",
+			},
+			"sharing": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      VLAN_SHARING_NONE,
+				ValidateFunc: validateVlanSharing,
+				Description:  "Private VLAN type: none, primary, isolated or community",
+			},
+			"primary_vlan": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Name of the primary VLAN an isolated or community VLAN belongs to",
+			},
+			"native": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether the VLAN is the native VLAN of the uplinks",
+			},
+			"multicast_policy": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"dn": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceUcsVlanCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVlanCreate(...)\n")
+
+	err := checkVlanSharing(d.Get("sharing").(string), d.Get("primary_vlan").(string))
+	if err != nil {
+		return err
+	}
+
+	vlan := ucsclient.NewFabricVlan(cloudFabric(d.Get("fabric").(string)), d.Get("name").(string)).
+		Set("id", strconv.Itoa(d.Get("vlan_id").(int))).
+		SetStatus(ucsclient.StatusCreated)
+	setVlanAttributes(d, vlan)
+
+	client.Logger.Info("Creating VLAN \"%s\"\n", vlan.Dn())
+	_, err = client.ConfigConfMos(map[string]*ucsclient.ManagedObject{vlan.Dn(): vlan}, false)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(vlan.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsVlanCreate(...)\n")
+	return resourceUcsVlanRead(d, meta)
+}
+
+func resourceUcsVlanRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVlanRead(...)\n")
+
+	vlan, err := client.ResolveDn(d.Id(), false)
+	if err != nil {
+		return err
+	}
+
+	// The VLAN was deleted outside of Terraform.
+	if vlan == nil {
+		d.SetId("")
+		return nil
+	}
+
+	fabric, _, err := parseVlanDn(vlan.Dn())
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(vlan.Get("id"))
+	if err != nil {
+		return fmt.Errorf("Invalid id of VLAN %s: %q", vlan.Dn(), vlan.Get("id"))
+	}
+
+	d.Set("name", vlan.Get("name"))
+	d.Set("vlan_id", id)
+	d.Set("fabric", fabric)
+	d.Set("sharing", vlan.Get("sharing"))
+	d.Set("primary_vlan", vlan.Get("pubNwName"))
+	d.Set("native", vlan.Get("defaultNet") == "yes")
+	d.Set("multicast_policy", vlan.Get("mcastPolicyName"))
+	d.Set("dn", vlan.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsVlanRead(...)\n")
+	return nil
+}
+
+// The name, id and fabric of a VLAN can't be changed in place (see ForceNew
+// in the schema); everything else can.
+func resourceUcsVlanUpdate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVlanUpdate(...)\n")
+
+	err := checkVlanSharing(d.Get("sharing").(string), d.Get("primary_vlan").(string))
+	if err != nil {
+		return err
+	}
+
+	vlan := ucsclient.NewManagedObject("fabricVlan", d.Id()).SetStatus(ucsclient.StatusModified)
+	setVlanAttributes(d, vlan)
+
+	_, err = client.ConfigConfMos(map[string]*ucsclient.ManagedObject{vlan.Dn(): vlan}, false)
+	if err != nil {
+		return err
+	}
+
+	client.Logger.Debug("Exiting resourceUcsVlanUpdate(...)\n")
+	return resourceUcsVlanRead(d, meta)
+}
+
+func resourceUcsVlanDelete(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVlanDelete(...)\n")
+
+	vlan := ucsclient.NewManagedObject("fabricVlan", d.Id()).SetStatus(ucsclient.StatusDeleted)
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{vlan.Dn(): vlan}, false)
+	if err != nil {
+		return err
+	}
+
+	d.SetId("")
+
+	client.Logger.Debug("Exiting resourceUcsVlanDelete(...)\n")
+	return nil
+}
+
+// Imports an existing VLAN given its DN, e.g.
+// `terraform import ucs_vlan.x fabric/lan/net-web`.
+func resourceUcsVlanImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if _, _, err := parseVlanDn(d.Id()); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+// Sets the attributes of the VLAN which can be changed in place.
+func setVlanAttributes(d *schema.ResourceData, vlan *ucsclient.ManagedObject) {
+	vlan.Set("sharing", d.Get("sharing").(string)).
+		Set("pubNwName", d.Get("primary_vlan").(string)).
+		Set("defaultNet", yesNo(d.Get("native").(bool))).
+		Set("mcastPolicyName", d.Get("multicast_policy").(string))
+}
+
+// Returns the fabric interconnect of the LAN or SAN cloud a VLAN or VSAN of
+// the given fabric lives in, blank for dual ones.
+func cloudFabric(fabric string) string {
+	if fabric == FABRIC_DUAL {
+		return ""
+	}
+	return fabric
+}
+
+// Splits the DN of a VLAN, e.g. fabric/lan/A/net-web, into its fabric (A,
+// B or dual) and name.
+func parseVlanDn(dn string) (fabric, name string, err error) {
+	return parseFabricNetDn("fabric/lan", dn)
+}
+
+// Splits the DN of a VLAN or VSAN of the given cloud (fabric/lan or
+// fabric/san) into its fabric (A, B or dual) and name.
+func parseFabricNetDn(cloud, dn string) (fabric, name string, err error) {
+	invalid := fmt.Errorf("Invalid DN %q: expected %s/[A/|B/]net-<name>", dn, cloud)
+	rest := strings.TrimPrefix(dn, cloud+"/")
+	if rest == dn {
+		return "", "", invalid
+	}
+
+	fabric = FABRIC_DUAL
+	if i := strings.Index(rest, "/"); i >= 0 {
+		fabric, rest = rest[:i], rest[i+1:]
+		if fabric != FABRIC_A && fabric != FABRIC_B {
+			return "", "", invalid
+		}
+	}
+
+	if !strings.HasPrefix(rest, "net-") || rest == "net-" || strings.Contains(rest, "/") {
+		return "", "", invalid
+	}
+	return fabric, strings.TrimPrefix(rest, "net-"), nil
+}
+
+// Checks that isolated and community VLANs, and only them, belong to a
+// primary VLAN.
+func checkVlanSharing(sharing, primary string) error {
+	switch sharing {
+	case VLAN_SHARING_ISOLATED, VLAN_SHARING_COMMUNITY:
+		if primary == "" {
+			return fmt.Errorf("primary_vlan is required for %s VLANs", sharing)
+		}
+	default:
+		if primary != "" {
+			return fmt.Errorf("primary_vlan is only allowed for isolated and community VLANs; got %s", sharing)
+		}
+	}
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func validateVlanID(v interface{}, k string) (ws []string, errors []error) {
+	id := v.(int)
+	if id < VLAN_ID_MIN || id > VLAN_ID_MAX {
+		errors = append(errors, fmt.Errorf("%q must be between %d and %d; got %d", k, VLAN_ID_MIN, VLAN_ID_MAX, id))
+	} else if id >= VLAN_RESERVED_FIRST && id <= VLAN_RESERVED_LAST {
+		errors = append(errors, fmt.Errorf("%q must not be one of the reserved VLANs %d to %d; got %d", k, VLAN_RESERVED_FIRST, VLAN_RESERVED_LAST, id))
+	}
+	return
+}
+
+func validateFabric(v interface{}, k string) (ws []string, errors []error) {
+	return validateOneOf(fabrics, v, k)
+}
+
+func validateVlanSharing(v interface{}, k string) (ws []string, errors []error) {
+	return validateOneOf(vlanSharings, v, k)
+}
+
+func validateOneOf(values []string, v interface{}, k string) (ws []string, errors []error) {
+	s := v.(string)
+	for _, value := range values {
+		if value == s {
+			return
+		}
+	}
+	errors = append(errors, fmt.Errorf("%q must be one of %s; got %q", k, strings.Join(values, ", "), s))
+	return
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// A VLAN group (fabricNetGroup) along with its member VLANs and the uplink
+// ports and port channels it is bound to. Members and uplinks are added and
+// removed one by one, leaving the group and the others untouched.
+func resourceUcsVlanGroup() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceUcsVlanGroupCreate,
+		Read:   resourceUcsVlanGroupRead,
+		Update: resourceUcsVlanGroupUpdate,
+		Delete: resourceUcsVlanGroupDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"vlans": &schema.Schema{
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Names of the member VLANs",
+			},
+			"uplink_port": &schema.Schema{
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"fabric": &schema.Schema{
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateSingleFabric,
+						},
+						"slot_id": &schema.Schema{
+							Type:     schema.TypeInt,
+							Required: true,
+						},
+						"port_id": &schema.Schema{
+							Type:     schema.TypeInt,
+							Required: true,
+						},
+					},
+				},
+			},
+			"uplink_port_channel": &schema.Schema{
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"fabric": &schema.Schema{
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateSingleFabric,
+						},
+						"port_channel_id": &schema.Schema{
+							Type:     schema.TypeInt,
+							Required: true,
+						},
+					},
+				},
+			},
+			"dn": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceUcsVlanGroupCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVlanGroupCreate(...)\n")
+
+	group := ucsclient.NewFabricNetGroup(d.Get("name").(string)).SetStatus(ucsclient.StatusCreated)
+	members := expandVlanGroupMembers(group.Dn(), setList(d.Get("vlans")), setList(d.Get("uplink_port")), setList(d.Get("uplink_port_channel")))
+	for _, child := range diffVlanGroupMembers(nil, members) {
+		group.AddChild(child)
+	}
+
+	client.Logger.Info("Creating VLAN group \"%s\"\n", group.Dn())
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{group.Dn(): group}, true)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(group.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsVlanGroupCreate(...)\n")
+	return resourceUcsVlanGroupRead(d, meta)
+}
+
+func resourceUcsVlanGroupRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVlanGroupRead(...)\n")
+
+	group, err := client.ResolveDn(d.Id(), true)
+	if err != nil {
+		return err
+	}
+
+	// The VLAN group was deleted outside of Terraform.
+	if group == nil {
+		d.SetId("")
+		return nil
+	}
+
+	vlans := make([]interface{}, 0)
+	for _, child := range group.ChildrenByClass("fabricPooledVlan") {
+		vlans = append(vlans, child.Get("name"))
+	}
+
+	ports := make([]interface{}, 0)
+	for _, child := range group.ChildrenByClass("fabricEthVlanPortEp") {
+		ports = append(ports, map[string]interface{}{
+			"fabric":  child.Get("switchId"),
+			"slot_id": atoiOrZero(child.Get("slotId")),
+			"port_id": atoiOrZero(child.Get("portId")),
+		})
+	}
+
+	portChannels := make([]interface{}, 0)
+	for _, child := range group.ChildrenByClass("fabricEthVlanPc") {
+		portChannels = append(portChannels, map[string]interface{}{
+			"fabric":          child.Get("switchId"),
+			"port_channel_id": atoiOrZero(child.Get("portId")),
+		})
+	}
+
+	d.Set("name", group.Get("name"))
+	d.Set("vlans", vlans)
+	d.Set("uplink_port", ports)
+	d.Set("uplink_port_channel", portChannels)
+	d.Set("dn", group.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsVlanGroupRead(...)\n")
+	return nil
+}
+
+// Adds the new members and uplinks to the group and removes the ones which
+// are gone in a single transaction.
+func resourceUcsVlanGroupUpdate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVlanGroupUpdate(...)\n")
+
+	oldVlans, newVlans := d.GetChange("vlans")
+	oldPorts, newPorts := d.GetChange("uplink_port")
+	oldPortChannels, newPortChannels := d.GetChange("uplink_port_channel")
+
+	old := expandVlanGroupMembers(d.Id(), setList(oldVlans), setList(oldPorts), setList(oldPortChannels))
+	members := expandVlanGroupMembers(d.Id(), setList(newVlans), setList(newPorts), setList(newPortChannels))
+
+	changes := diffVlanGroupMembers(old, members)
+	if len(changes) > 0 {
+		group := ucsclient.NewManagedObject("fabricNetGroup", d.Id()).SetStatus(ucsclient.StatusModified)
+		for _, child := range changes {
+			group.AddChild(child)
+		}
+
+		_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{group.Dn(): group}, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	client.Logger.Debug("Exiting resourceUcsVlanGroupUpdate(...)\n")
+	return resourceUcsVlanGroupRead(d, meta)
+}
+
+func resourceUcsVlanGroupDelete(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVlanGroupDelete(...)\n")
+
+	group := ucsclient.NewManagedObject("fabricNetGroup", d.Id()).SetStatus(ucsclient.StatusDeleted)
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{group.Dn(): group}, false)
+	if err != nil {
+		return err
+	}
+
+	d.SetId("")
+
+	client.Logger.Debug("Exiting resourceUcsVlanGroupDelete(...)\n")
+	return nil
+}
+
+// Builds the member VLANs, uplink ports and uplink port channels of the
+// VLAN group identified by groupDn, keyed by DN.
+func expandVlanGroupMembers(groupDn string, vlans, ports, portChannels []interface{}) map[string]*ucsclient.ManagedObject {
+	members := make(map[string]*ucsclient.ManagedObject)
+	for _, vlan := range vlans {
+		mo := ucsclient.NewFabricPooledVlan(groupDn, vlan.(string))
+		members[mo.Dn()] = mo
+	}
+
+	for _, raw := range ports {
+		port := raw.(map[string]interface{})
+		mo := ucsclient.NewFabricEthVlanPortEp(groupDn, port["fabric"].(string), port["slot_id"].(int), port["port_id"].(int))
+		members[mo.Dn()] = mo
+	}
+
+	for _, raw := range portChannels {
+		pc := raw.(map[string]interface{})
+		mo := ucsclient.NewFabricEthVlanPc(groupDn, pc["fabric"].(string), pc["port_channel_id"].(int))
+		members[mo.Dn()] = mo
+	}
+	return members
+}
+
+// Returns the members to be created (those only in members) and deleted
+// (those only in old), sorted by DN.
+func diffVlanGroupMembers(old, members map[string]*ucsclient.ManagedObject) []*ucsclient.ManagedObject {
+	return diffChildren(old, members)
+}
+
+// Returns the items of a TypeSet attribute, or none if it is not set.
+func setList(v interface{}) []interface{} {
+	if set, ok := v.(*schema.Set); ok && set != nil {
+		return set.List()
+	}
+	return nil
+}
+
+func atoiOrZero(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
+
+func validateSingleFabric(v interface{}, k string) (ws []string, errors []error) {
+	return validateOneOf([]string{FABRIC_A, FABRIC_B}, v, k)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+)
+
+func TestExpandVlanGroupMembers(t *testing.T) {
+	members := expandVlanGroupMembers("fabric/lan/net-group-web",
+		[]interface{}{"web", "db"},
+		[]interface{}{map[string]interface{}{"fabric": "A", "slot_id": 1, "port_id": 17}},
+		[]interface{}{map[string]interface{}{"fabric": "B", "port_channel_id": 2}},
+	)
+
+	expected := map[string]string{
+		"fabric/lan/net-group-web/net-web":                      "fabricPooledVlan",
+		"fabric/lan/net-group-web/net-db":                       "fabricPooledVlan",
+		"fabric/lan/net-group-web/phys-switch-A-slot-1-port-17": "fabricEthVlanPortEp",
+		"fabric/lan/net-group-web/pc-switch-B-pc-2":             "fabricEthVlanPc",
+	}
+	if len(members) != len(expected) {
+		t.Fatalf("%d members expected; got %d", len(expected), len(members))
+	}
+
+	for dn, class := range expected {
+		mo, ok := members[dn]
+		if !ok {
+			t.Errorf("%s expected; got %v", dn, members)
+			continue
+		}
+
+		if mo.ClassId != class {
+			t.Errorf("%s expected; got %s", class, mo.ClassId)
+		}
+	}
+
+	if port := members["fabric/lan/net-group-web/phys-switch-A-slot-1-port-17"].Get("portId"); port != "17" {
+		t.Errorf("%s expected; got %s", "17", port)
+	}
+}
+
+func TestDiffVlanGroupMembers(t *testing.T) {
+	groupDn := "fabric/lan/net-group-web"
+	old := expandVlanGroupMembers(groupDn, []interface{}{"web", "db"}, nil, nil)
+	members := expandVlanGroupMembers(groupDn, []interface{}{"web", "cache"}, nil, []interface{}{
+		map[string]interface{}{"fabric": "A", "port_channel_id": 1},
+	})
+
+	changes := diffVlanGroupMembers(old, members)
+
+	expected := []struct {
+		dn     string
+		status string
+	}{
+		{"fabric/lan/net-group-web/net-cache", ucsclient.StatusCreated},
+		{"fabric/lan/net-group-web/net-db", ucsclient.StatusDeleted},
+		{"fabric/lan/net-group-web/pc-switch-A-pc-1", ucsclient.StatusCreated},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("%d changes expected; got %d", len(expected), len(changes))
+	}
+
+	for i, change := range changes {
+		if change.Dn() != expected[i].dn || change.Get("status") != expected[i].status {
+			t.Errorf("%s %s expected; got %s %s", expected[i].status, expected[i].dn, change.Get("status"), change.Dn())
+		}
+	}
+
+	if changes := diffVlanGroupMembers(members, members); len(changes) != 0 {
+		t.Errorf("no changes expected; got %d", len(changes))
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestValidateVlanID(t *testing.T) {
+	for _, id := range []int{1, 100, 3914, 4048, 4093} {
+		if _, errs := validateVlanID(id, "vlan_id"); len(errs) > 0 {
+			t.Errorf("no errors expected for %d; got %v", id, errs)
+		}
+	}
+
+	for _, id := range []int{0, -1, 3915, 4000, 4047, 4094, 5000} {
+		if _, errs := validateVlanID(id, "vlan_id"); len(errs) == 0 {
+			t.Errorf("error expected for %d; got none", id)
+		}
+	}
+}
+
+func TestValidateFabric(t *testing.T) {
+	for _, fabric := range []string{"A", "B", "dual"} {
+		if _, errs := validateFabric(fabric, "fabric"); len(errs) > 0 {
+			t.Errorf("no errors expected for %s; got %v", fabric, errs)
+		}
+	}
+
+	if _, errs := validateFabric("C", "fabric"); len(errs) == 0 {
+		t.Errorf("error expected for C; got none")
+	}
+}
+
+func TestParseVlanDn(t *testing.T) {
+	tests := []struct {
+		dn     string
+		fabric string
+		name   string
+	}{
+		{"fabric/lan/net-web", "dual", "web"},
+		{"fabric/lan/A/net-web", "A", "web"},
+		{"fabric/lan/B/net-csvlan2009", "B", "csvlan2009"},
+	}
+
+	for _, test := range tests {
+		fabric, name, err := parseVlanDn(test.dn)
+		if err != nil {
+			t.Errorf("parseVlanDn(%s) returned unexpected error %v", test.dn, err)
+			continue
+		}
+
+		if fabric != test.fabric || name != test.name {
+			t.Errorf("%s, %s expected for %s; got %s, %s", test.fabric, test.name, test.dn, fabric, name)
+		}
+	}
+
+	for _, dn := range []string{"fabric/san/net-web", "fabric/lan/C/net-web", "fabric/lan/net-", "fabric/lan/A/net-web/foo", "net-web"} {
+		if _, _, err := parseVlanDn(dn); err == nil {
+			t.Errorf("error expected for %s; got nil", dn)
+		}
+	}
+}
+
+func TestCheckVlanSharing(t *testing.T) {
+	if err := checkVlanSharing("none", ""); err != nil {
+		t.Errorf("no error expected; got %v", err)
+	}
+
+	if err := checkVlanSharing("isolated", "pvlan-primary"); err != nil {
+		t.Errorf("no error expected; got %v", err)
+	}
+
+	if err := checkVlanSharing("community", ""); err == nil {
+		t.Errorf("error expected for a community VLAN without a primary one; got nil")
+	}
+
+	if err := checkVlanSharing("primary", "pvlan-primary"); err == nil {
+		t.Errorf("error expected for a primary VLAN belonging to another one; got nil")
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/CiscoUcs/UCS-Terraform/ucsclient"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Whether the fabric interconnects act as FC zone servers for a VSAN.
+const (
+	ZONING_STATE_DISABLED = "disabled"
+	ZONING_STATE_ENABLED  = "enabled"
+)
+
+// IDs UCS accepts for VSANs. VSAN_RESERVED is kept for internal use by the
+// fabric interconnects.
+const (
+	VSAN_ID_MIN   = 1
+	VSAN_ID_MAX   = 4093
+	VSAN_RESERVED = 4079
+)
+
+// A VSAN of the SAN cloud (fabricVsan), e.g. fabric/san/A/net-storage,
+// which the vHBAs of service profiles are bound to.
+func resourceUcsVsan() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceUcsVsanCreate,
+		Read:   resourceUcsVsanRead,
+		Update: resourceUcsVsanUpdate,
+		Delete: resourceUcsVsanDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceUcsVsanImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"vsan_id": &schema.Schema{
+				Type:         schema.TypeInt,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateVsanID,
+			},
+			"fcoe_vlan": &schema.Schema{
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validateVlanID,
+				Description:  "ID of the VLAN carrying the FCoE traffic of the VSAN",
+			},
+			"fabric": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      FABRIC_DUAL,
+				ForceNew:     true,
+				ValidateFunc: validateFabric,
+				Description:  "Fabric interconnect the VSAN is defined on: A, B or dual",
+			},
+			"zoning_state": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      ZONING_STATE_DISABLED,
+				ValidateFunc: validateZoningState,
+				Description:  "Whether the fabric interconnects do the FC zoning of the VSAN: enabled or disabled",
+			},
+			"dn": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceUcsVsanCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVsanCreate(...)\n")
+
+	vsan := ucsclient.NewFabricVsan(cloudFabric(d.Get("fabric").(string)), d.Get("name").(string)).
+		Set("id", strconv.Itoa(d.Get("vsan_id").(int))).
+		SetStatus(ucsclient.StatusCreated)
+	setVsanAttributes(d, vsan)
+
+	client.Logger.Info("Creating VSAN \"%s\"\n", vsan.Dn())
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{vsan.Dn(): vsan}, false)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(vsan.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsVsanCreate(...)\n")
+	return resourceUcsVsanRead(d, meta)
+}
+
+func resourceUcsVsanRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVsanRead(...)\n")
+
+	vsan, err := client.ResolveDn(d.Id(), false)
+	if err != nil {
+		return err
+	}
+
+	// The VSAN was deleted outside of Terraform.
+	if vsan == nil {
+		d.SetId("")
+		return nil
+	}
+
+	fabric, _, err := parseVsanDn(vsan.Dn())
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(vsan.Get("id"))
+	if err != nil {
+		return fmt.Errorf("Invalid id of VSAN %s: %q", vsan.Dn(), vsan.Get("id"))
+	}
+
+	fcoeVlan, err := strconv.Atoi(vsan.Get("fcoeVlan"))
+	if err != nil {
+		return fmt.Errorf("Invalid FCoE VLAN of VSAN %s: %q", vsan.Dn(), vsan.Get("fcoeVlan"))
+	}
+
+	d.Set("name", vsan.Get("name"))
+	d.Set("vsan_id", id)
+	d.Set("fcoe_vlan", fcoeVlan)
+	d.Set("fabric", fabric)
+	d.Set("zoning_state", vsan.Get("zoningState"))
+	d.Set("dn", vsan.Dn())
+
+	client.Logger.Debug("Exiting resourceUcsVsanRead(...)\n")
+	return nil
+}
+
+// The name, id and fabric of a VSAN can't be changed in place (see ForceNew
+// in the schema); its FCoE VLAN and zoning state can.
+func resourceUcsVsanUpdate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVsanUpdate(...)\n")
+
+	vsan := ucsclient.NewManagedObject("fabricVsan", d.Id()).SetStatus(ucsclient.StatusModified)
+	setVsanAttributes(d, vsan)
+
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{vsan.Dn(): vsan}, false)
+	if err != nil {
+		return err
+	}
+
+	client.Logger.Debug("Exiting resourceUcsVsanUpdate(...)\n")
+	return resourceUcsVsanRead(d, meta)
+}
+
+func resourceUcsVsanDelete(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*providerMeta).client
+	client.Logger.Debug("Entering resourceUcsVsanDelete(...)\n")
+
+	vsan := ucsclient.NewManagedObject("fabricVsan", d.Id()).SetStatus(ucsclient.StatusDeleted)
+	_, err := client.ConfigConfMos(map[string]*ucsclient.ManagedObject{vsan.Dn(): vsan}, false)
+	if err != nil {
+		return err
+	}
+
+	d.SetId("")
+
+	client.Logger.Debug("Exiting resourceUcsVsanDelete(...)\n")
+	return nil
+}
+
+// Imports an existing VSAN given its DN, e.g.
+// `terraform import ucs_vsan.x fabric/san/A/net-storage`.
+func resourceUcsVsanImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if _, _, err := parseVsanDn(d.Id()); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+// Sets the attributes of the VSAN which can be changed in place.
+func setVsanAttributes(d *schema.ResourceData, vsan *ucsclient.ManagedObject) {
+	vsan.Set("fcoeVlan", strconv.Itoa(d.Get("fcoe_vlan").(int))).
+		Set("zoningState", d.Get("zoning_state").(string))
+}
+
+// Splits the DN of a VSAN, e.g. fabric/san/A/net-storage, into its fabric
+// (A, B or dual) and name.
+func parseVsanDn(dn string) (fabric, name string, err error) {
+	return parseFabricNetDn("fabric/san", dn)
+}
+
+func validateVsanID(v interface{}, k string) (ws []string, errors []error) {
+	id := v.(int)
+	if id < VSAN_ID_MIN || id > VSAN_ID_MAX {
+		errors = append(errors, fmt.Errorf("%q must be between %d and %d; got %d", k, VSAN_ID_MIN, VSAN_ID_MAX, id))
+	} else if id == VSAN_RESERVED {
+		errors = append(errors, fmt.Errorf("%q must not be the reserved VSAN %d", k, VSAN_RESERVED))
+	}
+	return
+}
+
+func validateZoningState(v interface{}, k string) (ws []string, errors []error) {
+	return validateOneOf([]string{ZONING_STATE_DISABLED, ZONING_STATE_ENABLED}, v, k)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestValidateVsanID(t *testing.T) {
+	for _, id := range []int{1, 100, 4078, 4080, 4093} {
+		if _, errs := validateVsanID(id, "vsan_id"); len(errs) > 0 {
+			t.Errorf("no errors expected for %d; got %v", id, errs)
+		}
+	}
+
+	for _, id := range []int{0, -1, 4079, 4094, 5000} {
+		if _, errs := validateVsanID(id, "vsan_id"); len(errs) == 0 {
+			t.Errorf("error expected for %d; got none", id)
+		}
+	}
+}
+
+func TestValidateZoningState(t *testing.T) {
+	for _, state := range []string{"enabled", "disabled"} {
+		if _, errs := validateZoningState(state, "zoning_state"); len(errs) > 0 {
+			t.Errorf("no errors expected for %s; got %v", state, errs)
+		}
+	}
+
+	if _, errs := validateZoningState("yes", "zoning_state"); len(errs) == 0 {
+		t.Errorf("error expected for yes; got none")
+	}
+}
+
+func TestParseVsanDn(t *testing.T) {
+	tests := []struct {
+		dn     string
+		fabric string
+		name   string
+	}{
+		{"fabric/san/net-storage", "dual", "storage"},
+		{"fabric/san/A/net-storage", "A", "storage"},
+		{"fabric/san/B/net-vsan-b", "B", "vsan-b"},
+	}
+
+	for _, test := range tests {
+		fabric, name, err := parseVsanDn(test.dn)
+		if err != nil {
+			t.Errorf("parseVsanDn(%s) returned unexpected error %v", test.dn, err)
+			continue
+		}
+
+		if fabric != test.fabric || name != test.name {
+			t.Errorf("%s, %s expected for %s; got %s, %s", test.fabric, test.name, test.dn, fabric, name)
+		}
+	}
+
+	for _, dn := range []string{"fabric/lan/net-storage", "fabric/san/C/net-storage", "fabric/san/net-", "net-storage"} {
+		if _, _, err := parseVsanDn(dn); err == nil {
+			t.Errorf("error expected for %s; got nil", dn)
+		}
+	}
+}
+
+func TestVsanFcoeVlanRejectsReservedVlans(t *testing.T) {
+	validate := resourceUcsVsan().Schema["fcoe_vlan"].ValidateFunc
+	for _, id := range []int{3915, 4000, 4047} {
+		if _, errs := validate(id, "fcoe_vlan"); len(errs) == 0 {
+			t.Errorf("error expected for %d; got none", id)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package ucsclient
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Typed builders for the managed objects the provider writes through
+// ConfigConfMos. They only fill in the class and the mandatory naming
+// attributes; anything else can be added with ManagedObject.Set.
+
+// Returns an lsServer (service profile) managed object.
+func NewLsServer(dn string) *ManagedObject {
+	return NewManagedObject("lsServer", dn)
+}
+
+// Returns the lsPower child of the given service profile set to state
+// (up, down, soft-shut-down, cycle-immediate...).
+func NewLsPower(serverDn, state string) *ManagedObject {
+	return NewManagedObject("lsPower", serverDn+"/power").Set("state", state)
+}
+
+// Returns an orgOrg (organization) managed object called name within the
+// organization identified by parentDn.
+func NewOrgOrg(parentDn, name string) *ManagedObject {
+	return NewManagedObject("orgOrg", parentDn+"/org-"+name).Set("name", name)
+}
+
+// Returns a fabricVlan managed object called name in the LAN cloud, either
+// shared by both fabric interconnects (blank fabric) or specific to fabric
+// interconnect "A" or "B".
+func NewFabricVlan(fabric, name string) *ManagedObject {
+	return NewManagedObject("fabricVlan", LanCloudDn(fabric)+"/net-"+name).Set("name", name)
+}
+
+// Returns the DN of the LAN cloud of the given fabric interconnect ("A" or
+// "B"), or the one shared by both if fabric is blank.
+func LanCloudDn(fabric string) string {
+	if fabric == "" {
+		return "fabric/lan"
+	}
+	return "fabric/lan/" + fabric
+}
+
+// Returns a fabricNetGroup (VLAN group) managed object called name.
+func NewFabricNetGroup(name string) *ManagedObject {
+	return NewManagedObject("fabricNetGroup", "fabric/lan/net-group-"+name).Set("name", name)
+}
+
+// Returns the fabricPooledVlan making the VLAN called vlan a member of the
+// VLAN group identified by groupDn.
+func NewFabricPooledVlan(groupDn, vlan string) *ManagedObject {
+	return NewManagedObject("fabricPooledVlan", groupDn+"/net-"+vlan).Set("name", vlan)
+}
+
+// Returns the fabricEthVlanPortEp binding the VLAN group identified by
+// groupDn to an uplink port of fabric interconnect "A" or "B".
+func NewFabricEthVlanPortEp(groupDn, fabric string, slot, port int) *ManagedObject {
+	rn := fmt.Sprintf("phys-switch-%s-slot-%d-port-%d", fabric, slot, port)
+	return NewManagedObject("fabricEthVlanPortEp", groupDn+"/"+rn).
+		Set("switchId", fabric).
+		Set("slotId", strconv.Itoa(slot)).
+		Set("portId", strconv.Itoa(port))
+}
+
+// Returns the fabricEthVlanPc binding the VLAN group identified by groupDn
+// to an uplink port channel of fabric interconnect "A" or "B".
+func NewFabricEthVlanPc(groupDn, fabric string, portChannel int) *ManagedObject {
+	rn := fmt.Sprintf("pc-switch-%s-pc-%d", fabric, portChannel)
+	return NewManagedObject("fabricEthVlanPc", groupDn+"/"+rn).
+		Set("switchId", fabric).
+		Set("portId", strconv.Itoa(portChannel))
+}
+
+// Returns a fabricVsan managed object called name in the SAN cloud, either
+// shared by both fabric interconnects (blank fabric) or specific to fabric
+// interconnect "A" or "B".
+func NewFabricVsan(fabric, name string) *ManagedObject {
+	return NewManagedObject("fabricVsan", SanCloudDn(fabric)+"/net-"+name).Set("name", name)
+}
+
+// Returns the DN of the SAN cloud of the given fabric interconnect ("A" or
+// "B"), or the one shared by both if fabric is blank.
+func SanCloudDn(fabric string) string {
+	if fabric == "" {
+		return "fabric/san"
+	}
+	return "fabric/san/" + fabric
+}
+
+// Returns a macpoolPool (MAC pool) managed object called name within the
+// organization identified by orgDn.
+func NewMacpoolPool(orgDn, name string) *ManagedObject {
+	return NewManagedObject("macpoolPool", orgDn+"/mac-pool-"+name).Set("name", name)
+}
+
+// Returns the macpoolBlock child of the given MAC pool holding the addresses
+// from to to, both included.
+func NewMacpoolBlock(poolDn, from, to string) *ManagedObject {
+	return NewManagedObject("macpoolBlock", fmt.Sprintf("%s/block-%s-%s", poolDn, from, to)).
+		Set("from", from).
+		Set("to", to)
+}
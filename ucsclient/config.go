@@ -8,6 +8,9 @@ type Config struct {
 	LogLevel              int
 	LogFilename           string
 	AppName               string
+	// Maximum number of requests in flight at any given time. Zero means
+	// no limit.
+	MaxConcurrentRequests int
 }
 
 // Configures and returns a fully initialised UCSClient.
@@ -0,0 +1,58 @@
+package ucsclient
+
+import (
+	"fmt"
+
+	ucs "github.com/CiscoUcs/UCS-Terraform/ucsclient/ucsinternal"
+)
+
+// APIError is returned whenever UCS answers a method call with an error
+// (i.e. the response carries errorCode, errorDescr or invocationResult).
+type APIError struct {
+	Code             string
+	Description      string
+	InvocationResult string
+	Method           string
+	Dn               string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("UCS %s failed", e.Method)
+	if e.Dn != "" {
+		msg += fmt.Sprintf(" on %s", e.Dn)
+	}
+	msg += fmt.Sprintf(": error %s", e.Code)
+	if e.InvocationResult != "" {
+		msg += fmt.Sprintf(" (%s)", e.InvocationResult)
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// Inspects the response to a request and returns an *APIError if UCS
+// reported a failure. Documents which cannot be parsed are left for the
+// caller to deal with. When the response does not tell which DN it refers
+// to, the DN of the request (if any) is used.
+func checkResponse(payload, body []byte) error {
+	res, err := ucs.NewMethodResponse(body)
+	if err != nil || !res.Failed() {
+		return nil
+	}
+
+	dn := res.Dn
+	if dn == "" {
+		if req, err := ucs.NewMethodResponse(payload); err == nil {
+			dn = req.Dn
+		}
+	}
+
+	return &APIError{
+		Code:             res.ErrorCode,
+		Description:      res.ErrorDescr,
+		InvocationResult: res.InvocationResult,
+		Method:           res.XMLName.Local,
+		Dn:               dn,
+	}
+}
@@ -0,0 +1,81 @@
+package ucsclient
+
+import (
+	"encoding/xml"
+
+	ucs "github.com/CiscoUcs/UCS-Terraform/ucsclient/ucsinternal"
+)
+
+// A Filter narrows down the managed objects returned by a class query.
+// Filters are built with Eq, Ne, Wcard, And, Or and Not and can be nested
+// arbitrarily, e.g.:
+//
+//	And(Eq("lsServer", "type", "instance"), Not(Wcard("lsServer", "name", "^test-.*")))
+type Filter interface {
+	node() ucs.FilterNode
+}
+
+type propertyFilter struct {
+	op       string
+	class    string
+	property string
+	value    string
+}
+
+type logicalFilter struct {
+	op      string
+	filters []Filter
+}
+
+// Matches objects of the given class whose property equals value.
+func Eq(class, property, value string) Filter {
+	return &propertyFilter{"eq", class, property, value}
+}
+
+// Matches objects of the given class whose property does not equal value.
+func Ne(class, property, value string) Filter {
+	return &propertyFilter{"ne", class, property, value}
+}
+
+// Matches objects of the given class whose property matches the regular
+// expression in value.
+func Wcard(class, property, value string) Filter {
+	return &propertyFilter{"wcard", class, property, value}
+}
+
+// Matches objects satisfying all of the given filters.
+func And(filters ...Filter) Filter {
+	return &logicalFilter{"and", filters}
+}
+
+// Matches objects satisfying at least one of the given filters.
+func Or(filters ...Filter) Filter {
+	return &logicalFilter{"or", filters}
+}
+
+// Matches objects which do not satisfy the given filter.
+func Not(filter Filter) Filter {
+	return &logicalFilter{"not", []Filter{filter}}
+}
+
+func (f *propertyFilter) node() ucs.FilterNode {
+	return ucs.FilterNode{
+		XMLName: xml.Name{Local: f.op},
+		Attrs: []xml.Attr{
+			{Name: xml.Name{Local: "class"}, Value: f.class},
+			{Name: xml.Name{Local: "property"}, Value: f.property},
+			{Name: xml.Name{Local: "value"}, Value: f.value},
+		},
+	}
+}
+
+func (f *logicalFilter) node() ucs.FilterNode {
+	n := ucs.FilterNode{
+		XMLName:  xml.Name{Local: f.op},
+		Children: make([]ucs.FilterNode, 0, len(f.filters)),
+	}
+	for _, filter := range f.filters {
+		n.Children = append(n.Children, filter.node())
+	}
+	return n
+}
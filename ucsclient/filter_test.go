@@ -0,0 +1,45 @@
+package ucsclient
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestFilterMarshal(t *testing.T) {
+	tests := []struct {
+		Filter   Filter
+		Expected string
+	}{
+		{
+			Eq("lsServer", "name", "foo"),
+			`<eq class="lsServer" property="name" value="foo"></eq>`,
+		},
+		{
+			Ne("macpoolAddr", "assignedToDn", ""),
+			`<ne class="macpoolAddr" property="assignedToDn" value=""></ne>`,
+		},
+		{
+			Wcard("fabricVlan", "name", "^csvlan.*"),
+			`<wcard class="fabricVlan" property="name" value="^csvlan.*"></wcard>`,
+		},
+		{
+			And(Eq("lsServer", "type", "instance"), Not(Eq("lsServer", "assocState", "associated"))),
+			`<and><eq class="lsServer" property="type" value="instance"></eq><not><eq class="lsServer" property="assocState" value="associated"></eq></not></and>`,
+		},
+		{
+			Or(Eq("lsServer", "name", "foo"), Eq("lsServer", "name", "bar")),
+			`<or><eq class="lsServer" property="name" value="foo"></eq><eq class="lsServer" property="name" value="bar"></eq></or>`,
+		},
+	}
+
+	for _, test := range tests {
+		out, err := xml.Marshal(test.Filter.node())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(out) != test.Expected {
+			t.Errorf("%s expected; got %s", test.Expected, out)
+		}
+	}
+}
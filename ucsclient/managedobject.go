@@ -0,0 +1,127 @@
+package ucsclient
+
+import (
+	"encoding/xml"
+	"sort"
+
+	ucs "github.com/CiscoUcs/UCS-Terraform/ucsclient/ucsinternal"
+)
+
+// Values of the `status` attribute telling UCS what to do with a managed
+// object sent through ConfigConfMos.
+const (
+	StatusCreated  = "created"
+	StatusModified = "modified"
+	StatusDeleted  = "deleted"
+)
+
+// ManagedObject is a generic representation of any object in the UCS
+// management information tree (blades, pools, VLANs, policies...).
+// Attributes are kept as plain strings, exactly as UCS returns them.
+type ManagedObject struct {
+	ClassId    string
+	Attributes map[string]string
+	Children   []*ManagedObject
+}
+
+// Returns a new managed object of the given class identified by dn.
+// Children are not required to have a dn; use the "rn" attribute instead.
+func NewManagedObject(classId, dn string) *ManagedObject {
+	mo := &ManagedObject{
+		ClassId:    classId,
+		Attributes: make(map[string]string),
+		Children:   make([]*ManagedObject, 0),
+	}
+	if dn != "" {
+		mo.Attributes["dn"] = dn
+	}
+	return mo
+}
+
+// Returns the distinguished name of the managed object.
+func (mo *ManagedObject) Dn() string {
+	return mo.Get("dn")
+}
+
+// Returns the value of the given attribute or a blank string if the
+// managed object does not have it.
+func (mo *ManagedObject) Get(attr string) string {
+	return mo.Attributes[attr]
+}
+
+// Sets the value of the given attribute. Returns the managed object itself
+// so that calls can be chained.
+func (mo *ManagedObject) Set(attr, value string) *ManagedObject {
+	mo.Attributes[attr] = value
+	return mo
+}
+
+// Sets the status (StatusCreated, StatusModified or StatusDeleted) UCS
+// should apply to the managed object.
+func (mo *ManagedObject) SetStatus(status string) *ManagedObject {
+	return mo.Set("status", status)
+}
+
+// Appends a child to the managed object.
+func (mo *ManagedObject) AddChild(child *ManagedObject) *ManagedObject {
+	mo.Children = append(mo.Children, child)
+	return mo
+}
+
+// Returns the direct children of the managed object which belong to the
+// given class.
+func (mo *ManagedObject) ChildrenByClass(classId string) []*ManagedObject {
+	children := make([]*ManagedObject, 0)
+	for _, child := range mo.Children {
+		if child.ClassId == classId {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Converts the XML representation of a managed object into a ManagedObject.
+func newManagedObject(raw ucs.ManagedObject) *ManagedObject {
+	mo := &ManagedObject{
+		ClassId:    raw.XMLName.Local,
+		Attributes: make(map[string]string, len(raw.Attrs)),
+		Children:   make([]*ManagedObject, 0, len(raw.Children)),
+	}
+
+	for _, attr := range raw.Attrs {
+		mo.Attributes[attr.Name.Local] = attr.Value
+	}
+
+	for _, child := range raw.Children {
+		mo.Children = append(mo.Children, newManagedObject(child))
+	}
+	return mo
+}
+
+// Converts a ManagedObject into its XML representation. Attributes are
+// sorted by name so that the resulting document is deterministic.
+func (mo *ManagedObject) raw() ucs.ManagedObject {
+	names := make([]string, 0, len(mo.Attributes))
+	for name := range mo.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	raw := ucs.ManagedObject{
+		XMLName:  xml.Name{Local: mo.ClassId},
+		Attrs:    make([]xml.Attr, 0, len(names)),
+		Children: make([]ucs.ManagedObject, 0, len(mo.Children)),
+	}
+
+	for _, name := range names {
+		raw.Attrs = append(raw.Attrs, xml.Attr{
+			Name:  xml.Name{Local: name},
+			Value: mo.Attributes[name],
+		})
+	}
+
+	for _, child := range mo.Children {
+		raw.Children = append(raw.Children, child.raw())
+	}
+	return raw
+}
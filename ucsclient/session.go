@@ -0,0 +1,144 @@
+package ucsclient
+
+import (
+	"time"
+
+	ucs "github.com/CiscoUcs/UCS-Terraform/ucsclient/ucsinternal"
+)
+
+// Error code returned by UCS when a request carries a cookie which has
+// expired or is otherwise unknown to the server.
+const ERR_AUTHORIZATION_REQUIRED = "552"
+
+// Allows tests to control the passing of time.
+var now = time.Now
+
+// Builds the payload of a request for the given session cookie.
+type payloadBuilder func(cookie string) ([]byte, error)
+
+// Determines whether the given error was caused by an invalid or expired
+// session cookie.
+func IsSessionError(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.Code == ERR_AUTHORIZATION_REQUIRED
+}
+
+// Requests a new cookie for the current session using aaaRefresh.
+// Returns an error if anything goes wrong.
+func (c *UCSClient) Refresh() error {
+	c.sessionMutex.Lock()
+	defer c.sessionMutex.Unlock()
+
+	return c.refresh()
+}
+
+func (c *UCSClient) refresh() error {
+	req := ucs.RefreshRequest{
+		Username: c.username,
+		Password: c.password,
+		Cookie:   c.cookie,
+	}
+	payload, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+
+	data, err := c.Post(payload)
+	if err != nil {
+		return err
+	}
+
+	res, err := ucs.NewRefreshResponse(data)
+	if err != nil {
+		return err
+	}
+
+	c.cookie = res.OutCookie
+	c.outDomains = res.OutDomains
+	c.refreshPeriod = time.Duration(res.OutRefreshPeriod) * time.Second
+	c.lastRefresh = now()
+	c.Logger.Debug("Successfully refreshed session with cookie %s \n", c.cookie)
+	return nil
+}
+
+// Determines whether the session cookie is about to expire. Cookies are
+// refreshed once three quarters of the refresh period UCS handed out on
+// login have elapsed.
+func (c *UCSClient) refreshDue() bool {
+	if c.refreshPeriod <= 0 {
+		return false
+	}
+	return now().Sub(c.lastRefresh) >= c.refreshPeriod*3/4
+}
+
+// Makes sure the client holds a valid session, logging in or refreshing the
+// cookie as needed, and returns the session cookie. If the cookie cannot be
+// refreshed a new session is opened.
+func (c *UCSClient) session() (string, error) {
+	c.sessionMutex.Lock()
+	defer c.sessionMutex.Unlock()
+
+	if len(c.cookie) == 0 {
+		err := c.login()
+		return c.cookie, err
+	}
+
+	if c.refreshDue() {
+		err := c.refresh()
+		if err != nil {
+			c.Logger.Warn("Could not refresh session, logging in again: %s\n", err)
+			c.cookie = ""
+			err = c.login()
+			return c.cookie, err
+		}
+	}
+	return c.cookie, nil
+}
+
+// Opens a new session to replace the one identified by the rejected cookie
+// and returns the new cookie. Concurrent requests rejected with the same
+// cookie only cause one new login.
+func (c *UCSClient) renewSession(rejected string) (string, error) {
+	c.sessionMutex.Lock()
+	defer c.sessionMutex.Unlock()
+
+	if c.cookie != rejected && len(c.cookie) > 0 {
+		return c.cookie, nil
+	}
+
+	c.Logger.Info("Session is no longer valid, logging in again\n")
+	c.cookie = ""
+	err := c.login()
+	return c.cookie, err
+}
+
+// Posts a request which requires a session. The session is opened or
+// refreshed beforehand if needed and, should UCS reject the cookie anyway,
+// the client logs in again and retries the request once.
+func (c *UCSClient) postWithSession(build payloadBuilder) ([]byte, error) {
+	cookie, err := c.session()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := build(cookie)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.Post(payload)
+	if !IsSessionError(err) {
+		return data, err
+	}
+
+	cookie, err = c.renewSession(cookie)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err = build(cookie)
+	if err != nil {
+		return nil, err
+	}
+	return c.Post(payload)
+}
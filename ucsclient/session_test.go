@@ -0,0 +1,180 @@
+package ucsclient
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	utils "github.com/ContainerSolutions/go-utils"
+)
+
+// Replies to each request with the next body in Bodies and records every
+// payload it receives.
+type StubHTTPClientWithSequence struct {
+	Bodies   [][]byte
+	Payloads []string
+}
+
+func (c *StubHTTPClientWithSequence) Post(url string, bodyType string, body io.Reader) (*http.Response, error) {
+	payload, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	c.Payloads = append(c.Payloads, string(payload))
+
+	res := &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewReader(c.Bodies[0])),
+	}
+	c.Bodies = c.Bodies[1:]
+	return res, nil
+}
+
+func withFrozenTime(t time.Time, cb func()) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return t }
+	cb()
+}
+
+func TestLoginStoresRefreshPeriod(t *testing.T) {
+	body := []byte(`<aaaLogin cookie="" response="yes" outCookie="chipsahoy!" outRefreshPeriod="600" outPriv="admin" outDomains="" outChannel="noencssl" outEvtChannel="noencssl" outSessionId="session-123" outVersion="2.2" outName="john"></aaaLogin>`)
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.httpClient = StubHTTPClient{
+		StatusCode: 200,
+		Body:       body,
+	}
+
+	loggedInAt := time.Date(2016, 3, 1, 10, 0, 0, 0, time.UTC)
+	withFrozenTime(loggedInAt, func() {
+		utils.FailOnError(t, ucsClient.Login())
+	})
+
+	if ucsClient.refreshPeriod != 600*time.Second {
+		t.Errorf("%v expected; got %v", 600*time.Second, ucsClient.refreshPeriod)
+	}
+
+	if !ucsClient.lastRefresh.Equal(loggedInAt) {
+		t.Errorf("%v expected; got %v", loggedInAt, ucsClient.lastRefresh)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	pex := []byte(`<aaaRefresh inName="john" inPassword="doe" inCookie="chipsahoy!"></aaaRefresh>`)
+	body := []byte(`<aaaRefresh cookie="" response="yes" outCookie="oreo!" outRefreshPeriod="600" outPriv="admin" outDomains="" outChannel="noencssl" outEvtChannel="noencssl"></aaaRefresh>`)
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClientWithAssertion{
+		StatusCode:      200,
+		Body:            body,
+		ExpectedPayload: pex,
+		t:               t,
+	}
+
+	utils.FailOnError(t, ucsClient.Refresh())
+
+	if ucsClient.cookie != "oreo!" {
+		t.Errorf("%s expected; got %s", "oreo!", ucsClient.cookie)
+	}
+}
+
+func TestSessionIsOpenedLazily(t *testing.T) {
+	stub := &StubHTTPClientWithSequence{
+		Bodies: [][]byte{
+			[]byte(`<aaaLogin cookie="" response="yes" outCookie="chipsahoy!" outRefreshPeriod="600"></aaaLogin>`),
+			[]byte(`<configResolveClass cookie="chipsahoy!" response="yes" classId="lsServer"><outConfigs></outConfigs></configResolveClass>`),
+			[]byte(`<configResolveClass cookie="chipsahoy!" response="yes" classId="lsServer"><outConfigs></outConfigs></configResolveClass>`),
+		},
+	}
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.httpClient = stub
+
+	for i := 0; i < 2; i++ {
+		_, err := ucsClient.ResolveClass("lsServer", nil, false)
+		utils.FailOnError(t, err)
+	}
+
+	if len(stub.Payloads) != 3 {
+		t.Fatalf("3 requests expected; got %d: %v", len(stub.Payloads), stub.Payloads)
+	}
+
+	if !strings.HasPrefix(stub.Payloads[0], "<aaaLogin ") {
+		t.Errorf("aaaLogin expected; got %s", stub.Payloads[0])
+	}
+}
+
+func TestSessionIsRefreshedBeforeExpiry(t *testing.T) {
+	stub := &StubHTTPClientWithSequence{
+		Bodies: [][]byte{
+			[]byte(`<aaaRefresh cookie="" response="yes" outCookie="oreo!" outRefreshPeriod="600"></aaaRefresh>`),
+			[]byte(`<configResolveClass cookie="oreo!" response="yes" classId="lsServer"><outConfigs></outConfigs></configResolveClass>`),
+		},
+	}
+	loggedInAt := time.Date(2016, 3, 1, 10, 0, 0, 0, time.UTC)
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.httpClient = stub
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.refreshPeriod = 600 * time.Second
+	ucsClient.lastRefresh = loggedInAt
+
+	withFrozenTime(loggedInAt.Add(500*time.Second), func() {
+		_, err := ucsClient.ResolveClass("lsServer", nil, false)
+		utils.FailOnError(t, err)
+	})
+
+	expected := []string{
+		`<aaaRefresh inName="john" inPassword="doe" inCookie="chipsahoy!"></aaaRefresh>`,
+		`<configResolveClass cookie="oreo!" inHierarchical="false" classId="lsServer"></configResolveClass>`,
+	}
+	if len(stub.Payloads) != len(expected) {
+		t.Fatalf("%d requests expected; got %d: %v", len(expected), len(stub.Payloads), stub.Payloads)
+	}
+
+	for i := range expected {
+		if stub.Payloads[i] != expected[i] {
+			t.Errorf("%s expected; got %s", expected[i], stub.Payloads[i])
+		}
+	}
+}
+
+func TestReloginOnInvalidSession(t *testing.T) {
+	stub := &StubHTTPClientWithSequence{
+		Bodies: [][]byte{
+			[]byte(`<configResolveClass cookie="chipsahoy!" response="yes" errorCode="552" invocationResult="unidentified-fail" errorDescr="Authorization required"> </configResolveClass>`),
+			[]byte(`<aaaLogin cookie="" response="yes" outCookie="oreo!" outRefreshPeriod="600"></aaaLogin>`),
+			[]byte(`<configResolveClass cookie="oreo!" response="yes" classId="lsServer"><outConfigs><lsServer dn="org-root/ls-foo"/></outConfigs></configResolveClass>`),
+		},
+	}
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.httpClient = stub
+	ucsClient.cookie = "chipsahoy!"
+
+	objects, err := ucsClient.ResolveClass("lsServer", nil, false)
+	utils.FailOnError(t, err)
+
+	if len(objects) != 1 {
+		t.Errorf("1 object expected; got %d", len(objects))
+	}
+
+	retry := `<configResolveClass cookie="oreo!" inHierarchical="false" classId="lsServer"></configResolveClass>`
+	if stub.Payloads[2] != retry {
+		t.Errorf("%s expected; got %s", retry, stub.Payloads[2])
+	}
+}
+
+func TestIsSessionError(t *testing.T) {
+	if !IsSessionError(&APIError{Code: ERR_AUTHORIZATION_REQUIRED}) {
+		t.Errorf("expected true; got false")
+	}
+
+	if IsSessionError(&APIError{Code: "103"}) {
+		t.Errorf("expected false; got true")
+	}
+
+	if IsSessionError(nil) {
+		t.Errorf("expected false; got true")
+	}
+}
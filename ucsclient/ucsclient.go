@@ -12,7 +12,10 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	ucs "github.com/CiscoUcs/UCS-Terraform/ucsclient/ucsinternal"
 	"github.com/micdoher/GoUtils"
@@ -21,19 +24,62 @@ import (
 
 const BODY_TYPE_XML = "text/xml"
 
+// States reported by UCS while configuring and associating service profiles.
+const (
+	ASSOC_STATE_ASSOCIATED    = "associated"
+	CONFIG_STATE_APPLIED      = "applied"
+	CONFIG_STATE_APPLYING     = "applying"
+	CONFIG_STATE_FAILED       = "failed-to-apply"
+	FSM_STATUS_FAIL           = "fail"
+	FSM_STATUS_IN_PROGRESS    = "inProgress"
+	OPER_STATE_CONFIG_FAILURE = "config-failure"
+)
+
+// Power states of the server associated with a service profile.
+const (
+	POWER_STATE_UP                   = "up"
+	POWER_STATE_DOWN                 = "down"
+	POWER_STATE_SOFT_SHUT_DOWN       = "soft-shut-down"
+	POWER_STATE_CYCLE_IMMEDIATE      = "cycle-immediate"
+	POWER_STATE_HARD_RESET_IMMEDIATE = "hard-reset-immediate"
+)
+
 type (
+	// Fault raised by UCS against a service profile or one of its children.
+	Fault struct {
+		Dn       string
+		Code     string
+		Severity string
+		Cause    string
+		Descr    string
+		Created  string
+	}
+
 	HTTPClient interface {
 		Post(string, string, io.Reader) (*http.Response, error)
 	}
 
+	// The fields following VNICs are only known once the service profile
+	// has been read from UCS.
 	ServiceProfile struct {
 		Name         string
 		Template     string
 		TargetOrg    string
 		Hierarchical bool
 		VNICs        []VNIC
+		AssocState   string            `json:",omitempty"`
+		ConfigState  string            `json:",omitempty"`
+		OperState    string            `json:",omitempty"`
+		FsmStatus    string            `json:",omitempty"`
+		FsmError     string            `json:",omitempty"`
+		PowerState   string            `json:",omitempty"`
+		Faults       []Fault           `json:",omitempty"`
+		ConfigIssues map[string]string `json:",omitempty"`
 	}
 
+	// UCSClient is safe for concurrent use. All the requests share the same
+	// session, which is only locked while logging in, refreshing the cookie
+	// or logging out.
 	UCSClient struct {
 		httpClient            HTTPClient
 		ipAddress             string
@@ -42,15 +88,33 @@ type (
 		tslInsecureSkipVerify bool
 		cookie                string
 		outDomains            string
+		refreshPeriod         time.Duration
+		lastRefresh           time.Time
+		sessionMutex          sync.Mutex
+		requests              chan struct{}
 		appName               string
 		Logger                *utils.Logger
 	}
 
+	// The IPv6 fields are only set for dual-stack vNICs. IPv6Allocation
+	// tells how their IPv6 address is picked: "eui64" or "sequential".
+	// Exclude, RangeStart and RangeEnd narrow down the addresses which can
+	// be allocated to the vNIC. Gateway, DNSServers and Domain come from
+	// the pool the vNIC's IP is allocated from, if it has any.
 	VNIC struct {
-		Name string
-		Mac  string
-		CIDR string
-		Ip   net.IP
+		Name           string
+		Mac            string
+		CIDR           string
+		Ip             net.IP
+		Exclude        []string `json:",omitempty"`
+		RangeStart     string   `json:",omitempty"`
+		RangeEnd       string   `json:",omitempty"`
+		IPv6CIDR       string   `json:",omitempty"`
+		IPv6Allocation string   `json:",omitempty"`
+		IPv6           net.IP   `json:",omitempty"`
+		Gateway        net.IP   `json:",omitempty"`
+		DNSServers     []net.IP `json:",omitempty"`
+		Domain         string   `json:",omitempty"`
 	}
 )
 
@@ -86,8 +150,31 @@ func (sp *ServiceProfile) DN() string {
 	return sp.TargetOrg + "/ls-" + sp.Name
 }
 
+// Determines whether UCS is still busy configuring or associating the
+// service profile.
+func (sp *ServiceProfile) Busy() bool {
+	return sp.FsmStatus == FSM_STATUS_IN_PROGRESS || sp.ConfigState == CONFIG_STATE_APPLYING
+}
+
+// Determines whether the service profile has been associated with a server
+// and its configuration successfully applied.
+func (sp *ServiceProfile) Ready() bool {
+	return !sp.Busy() && sp.AssocState == ASSOC_STATE_ASSOCIATED && sp.ConfigState == CONFIG_STATE_APPLIED
+}
+
+// Determines whether UCS gave up configuring or associating the service
+// profile.
+func (sp *ServiceProfile) Failed() bool {
+	if sp.Busy() {
+		return false
+	}
+	return sp.FsmStatus == FSM_STATUS_FAIL ||
+		sp.ConfigState == CONFIG_STATE_FAILED ||
+		sp.OperState == OPER_STATE_CONFIG_FAILURE
+}
+
 func NewUCSClient(c *Config) *UCSClient {
-	client := UCSClient{
+	client := &UCSClient{
 		ipAddress:             c.IpAddress,
 		username:              c.Username,
 		password:              c.Password,
@@ -95,11 +182,15 @@ func NewUCSClient(c *Config) *UCSClient {
 		appName:               c.AppName,
 	}
 
+	if c.MaxConcurrentRequests > 0 {
+		client.requests = make(chan struct{}, c.MaxConcurrentRequests)
+	}
+
 	client.httpClient = NewHTTPClient(c.TslInsecureSkipVerify)
 	client.Logger = utils.NewLogger(getLogFile(c.LogFilename), c.LogLevel)
 	client.Logger.Print("Log level %v\n", c.LogLevel)
 
-	return &client
+	return client
 }
 
 func NewHTTPClient(insecureSkipVerify bool) *http.Client {
@@ -111,30 +202,128 @@ func NewHTTPClient(insecureSkipVerify bool) *http.Client {
 	}
 }
 
+// Deletes the service profile with the given name from targetOrg.
 func (c *UCSClient) Destroy(name, targetOrg string, hierarchical bool) error {
-	req := ucs.DestroyRequest{
-		Name:         name,
-		TargetOrg:    targetOrg,
-		Hierarchical: hierarchical,
+	sp := &ServiceProfile{
+		Name:      name,
+		TargetOrg: targetOrg,
+	}
+	configs := map[string]*ManagedObject{
+		sp.DN(): NewLsServer(sp.DN()).SetStatus(StatusDeleted),
+	}
+
+	_, err := c.ConfigConfMos(configs, hierarchical)
+	return err
+}
+
+// Sets the power state (see POWER_STATE_UP, POWER_STATE_DOWN...) of the
+// server associated with the service profile identified by dn.
+func (c *UCSClient) SetPowerState(dn, state string) error {
+	power := NewLsPower(dn, state).SetStatus(StatusModified)
+	configs := map[string]*ManagedObject{
+		power.Dn(): power,
 	}
-	payload, err := req.Marshal(c.cookie)
+
+	_, err := c.ConfigConfMos(configs, false)
+	return err
+}
+
+// Binds the service profile identified by dn to the service profile
+// template with the given name. A blank template unbinds the profile,
+// turning it into a standalone one which keeps its current configuration.
+func (c *UCSClient) SetServiceProfileTemplate(dn, template string) error {
+	configs := map[string]*ManagedObject{
+		dn: NewLsServer(dn).Set("srcTemplName", template).SetStatus(StatusModified),
+	}
+
+	_, err := c.ConfigConfMos(configs, false)
+	return err
+}
+
+// Renames the managed object identified by dn to newName.
+// Returns the renamed managed object, whose DN reflects the new name.
+func (c *UCSClient) ConfigConfRename(dn, newName string, hierarchical bool) (*ManagedObject, error) {
+	req := ucs.ConfigConfRenameRequest{
+		Dn:             dn,
+		InNewName:      newName,
+		InHierarchical: hierarchical,
+	}
+
+	data, err := c.postWithSession(func(cookie string) ([]byte, error) {
+		req.Cookie = cookie
+		return req.Marshal()
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = c.Post(payload)
+	res, err := ucs.NewConfigConfRenameResponse(data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if len(res.OutConfig.Objects) == 0 {
+		return nil, fmt.Errorf("UCS did not return %s after renaming it to %s", dn, newName)
+	}
+	return newManagedObject(res.OutConfig.Objects[0]), nil
+}
+
+// Creates, modifies or deletes the given managed objects in a single
+// configConfMos transaction. `configs` maps each DN to the managed object
+// to be applied, whose status attribute (see StatusCreated, StatusModified
+// and StatusDeleted) tells UCS what to do with it.
+// Returns the resulting managed objects keyed by DN.
+func (c *UCSClient) ConfigConfMos(configs map[string]*ManagedObject, hierarchical bool) (map[string]*ManagedObject, error) {
+	dns := make([]string, 0, len(configs))
+	for dn := range configs {
+		dns = append(dns, dn)
+	}
+	sort.Strings(dns)
+
+	req := ucs.ConfigConfMosRequest{
+		InHierarchical: hierarchical,
+		InConfigs:      make([]ucs.ConfigPair, 0, len(dns)),
+	}
+	for _, dn := range dns {
+		req.InConfigs = append(req.InConfigs, ucs.ConfigPair{
+			Key:    dn,
+			Object: configs[dn].raw(),
+		})
+	}
+
+	data, err := c.postWithSession(func(cookie string) ([]byte, error) {
+		req.Cookie = cookie
+		return req.Marshal()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := ucs.NewConfigConfMosResponse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string]*ManagedObject, len(res.OutConfigs))
+	for _, pair := range res.OutConfigs {
+		objects[pair.Key] = newManagedObject(pair.Object)
+	}
+	return objects, nil
 }
 
 // Performs a POST request to the UCS Server.
 // Returns a string with the response's body.
 // In case that an error happens it will return an empty string
-// along with an error.
+// along with an error. If UCS reports that the method call failed
+// the error will be an *APIError.
+// No more than Config.MaxConcurrentRequests requests are in flight at
+// any given time.
 func (c *UCSClient) Post(payload []byte) ([]byte, error) {
+	if c.requests != nil {
+		c.requests <- struct{}{}
+		defer func() { <-c.requests }()
+	}
+
 	c.Logger.Debug("POST %s\n", c.endpointURL())
 	c.Logger.Debug("Payload: %s\n", payload)
 
@@ -151,6 +340,12 @@ func (c *UCSClient) Post(payload []byte) ([]byte, error) {
 	}
 	c.Logger.Debug("Response: %s\n", body)
 
+	err = checkResponse(payload, body)
+	if err != nil {
+		c.Logger.Error("%s\n", err)
+		return nil, err
+	}
+
 	return body, nil
 }
 
@@ -158,6 +353,13 @@ func (c *UCSClient) Post(payload []byte) ([]byte, error) {
 // the config.
 // Returns an error if anything goes wrong.
 func (c *UCSClient) Login() error {
+	c.sessionMutex.Lock()
+	defer c.sessionMutex.Unlock()
+
+	return c.login()
+}
+
+func (c *UCSClient) login() error {
 	req := ucs.LoginRequest{
 		Username: c.username,
 		Password: c.password,
@@ -179,13 +381,18 @@ func (c *UCSClient) Login() error {
 
 	c.cookie = res.OutCookie
 	c.outDomains = res.OutDomains
+	c.refreshPeriod = time.Duration(res.OutRefreshPeriod) * time.Second
+	c.lastRefresh = now()
 	c.Logger.Debug("Successfully logged in with cookie %s \n", c.cookie)
 	return nil
 }
 
 // Logs out of the UCS server.
 func (c *UCSClient) Logout() {
-	if c.IsLoggedIn() {
+	c.sessionMutex.Lock()
+	defer c.sessionMutex.Unlock()
+
+	if len(c.cookie) > 0 {
 		c.Logger.Debug("Logging out\n")
 		req := ucs.LogoutRequest{
 			Cookie: c.cookie,
@@ -198,6 +405,7 @@ func (c *UCSClient) Logout() {
 		c.Post(payload)
 		c.cookie = ""
 		c.outDomains = ""
+		c.refreshPeriod = 0
 	}
 	c.Logger.Info("Logged out\n")
 }
@@ -206,12 +414,7 @@ func (c *UCSClient) Logout() {
 // Returns bool to indicate wether or not the resource could be created,
 // along with an error if anything went wrong.
 func (c *UCSClient) CreateServiceProfile(sp *ServiceProfile) (bool, error) {
-	payload, err := sp.Marshal(c.cookie)
-	if err != nil {
-		return false, err
-	}
-
-	data, err := c.Post(payload)
+	data, err := c.postWithSession(sp.Marshal)
 	if err != nil {
 		return false, err
 	}
@@ -231,6 +434,9 @@ func (c *UCSClient) CreateServiceProfile(sp *ServiceProfile) (bool, error) {
 // Determines if the UCSClient is logged into the server by
 // checking the presence of cookie.
 func (c *UCSClient) IsLoggedIn() bool {
+	c.sessionMutex.Lock()
+	defer c.sessionMutex.Unlock()
+
 	return len(c.cookie) > 0
 }
 
@@ -242,14 +448,16 @@ func getLogFile(filename string) *os.File {
 	return file
 }
 
+// Fetches the service profile identified by dn, which may live in a sub-org
+// (e.g. org-root/org-a/org-b/ls-x).
+// Returns nil without an error if UCS does not know about the profile.
 func (c *UCSClient) ConfigResolveDN(dn string) (*ServiceProfile, error) {
-	//construct the payload
-	payload := tplConfigResolveDn(c.cookie, dn)
-
 	//create a new configResolveDn object
 	crd := ucs.ConfigResolveDn{}
 	//query the UCS
-	data, err := c.Post(payload)
+	data, err := c.postWithSession(func(cookie string) ([]byte, error) {
+		return tplConfigResolveDn(cookie, dn), nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -261,26 +469,191 @@ func (c *UCSClient) ConfigResolveDN(dn string) (*ServiceProfile, error) {
 	}
 
 	if len(crd.OutConfig.ServerConfig) == 0 {
-		return nil, fmt.Errorf("No ServerConfig found.")
+		return nil, nil
+	}
+
+	//the DN of a profile lives in an org, e.g. org-root/ls-x
+	i := strings.LastIndex(dn, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("Invalid service profile DN %q: expected e.g. org-root/ls-foo", dn)
 	}
 
 	//instantiate a new ServiceProfile and fill it with the data
+	server := crd.OutConfig.ServerConfig[0]
 	sp := ServiceProfile{
-		Name:      crd.OutConfig.ServerConfig[0].Name,
-		Template:  crd.OutConfig.ServerConfig[0].SrcTempl,
-		TargetOrg: dn[0:strings.Index(dn, "/")],
-		VNICs:     make([]VNIC, 0, 1),
+		Name:        server.Name,
+		Template:    server.SrcTempl,
+		TargetOrg:   dn[0:i],
+		VNICs:       make([]VNIC, 0, 1),
+		AssocState:  server.AssocState,
+		ConfigState: server.ConfigState,
+		OperState:   server.OperState,
+		Faults:      newFaults(dn, server.Faults),
+	}
+
+	if server.Fsm != nil {
+		sp.FsmStatus = server.Fsm.FsmStatus
+		sp.FsmError = server.Fsm.RmtErrDescr
+	}
+
+	if server.Issues != nil {
+		sp.ConfigIssues = newConfigIssues(server.Issues)
 	}
 
-	for _, vnic := range crd.OutConfig.ServerConfig[0].VnicEther {
+	if server.Power != nil {
+		sp.PowerState = server.Power.State
+	}
+
+	for _, vnic := range server.VnicEther {
 		sp.VNICs = append(sp.VNICs, VNIC{
 			Name: vnic.Name,
 			Mac:  vnic.Addr,
 		})
+		sp.Faults = append(sp.Faults, newFaults(dn+"/ether-"+vnic.Name, vnic.Faults)...)
+	}
+
+	for _, vhba := range server.VnicFc {
+		sp.Faults = append(sp.Faults, newFaults(dn+"/fc-"+vhba.Name, vhba.Faults)...)
 	}
 	return &sp, nil
 }
 
+// Converts the faults raised against the managed object identified by dn.
+func newFaults(dn string, faults []ucs.FaultInst) []Fault {
+	ret := make([]Fault, 0, len(faults))
+	for _, f := range faults {
+		ret = append(ret, Fault{
+			Dn:       dn + "/" + f.Rn,
+			Code:     f.Code,
+			Severity: f.Severity,
+			Cause:    f.Cause,
+			Descr:    f.Descr,
+			Created:  f.Created,
+		})
+	}
+	return ret
+}
+
+// Returns the non-blank configuration issues of a service profile keyed by
+// the area they affect (server, network, storage, vnic, iscsi or warnings).
+func newConfigIssues(issues *ucs.LsIssues) map[string]string {
+	all := map[string]string{
+		"iscsi":    issues.IscsiConfigIssues,
+		"network":  issues.NetworkConfigIssues,
+		"server":   issues.ServerConfigIssues,
+		"storage":  issues.StorageConfigIssues,
+		"vnic":     issues.VnicConfigIssues,
+		"warnings": issues.ConfigWarnings,
+	}
+
+	ret := make(map[string]string)
+	for area, issue := range all {
+		if issue != "" {
+			ret[area] = issue
+		}
+	}
+	return ret
+}
+
+// Queries UCS for every managed object of the given class. The optional
+// filter (nil means no filter) narrows down the results and, when
+// hierarchical is true, each object is returned along with its children.
+func (c *UCSClient) ResolveClass(classId string, filter Filter, hierarchical bool) ([]*ManagedObject, error) {
+	req := ucs.ConfigResolveClassRequest{
+		ClassId:        classId,
+		InHierarchical: hierarchical,
+	}
+	if filter != nil {
+		req.InFilter = &ucs.InFilter{
+			Filter: filter.node(),
+		}
+	}
+
+	data, err := c.postWithSession(func(cookie string) ([]byte, error) {
+		req.Cookie = cookie
+		return req.Marshal()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := ucs.NewConfigResolveClassResponse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*ManagedObject, 0, len(res.OutConfigs.Objects))
+	for _, raw := range res.OutConfigs.Objects {
+		objects = append(objects, newManagedObject(raw))
+	}
+	return objects, nil
+}
+
+// Fetches the managed object identified by dn, along with its children
+// when hierarchical is true.
+// Returns nil without an error if UCS does not know about the object.
+func (c *UCSClient) ResolveDn(dn string, hierarchical bool) (*ManagedObject, error) {
+	req := ucs.ConfigResolveDnRequest{
+		Dn:             dn,
+		InHierarchical: hierarchical,
+	}
+
+	data, err := c.postWithSession(func(cookie string) ([]byte, error) {
+		req.Cookie = cookie
+		return req.Marshal()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := ucs.NewConfigResolveDnResponse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.OutConfig.Objects) == 0 {
+		return nil, nil
+	}
+	return newManagedObject(res.OutConfig.Objects[0]), nil
+}
+
+// Returns the DNs of the vNICs called name across the service profiles
+// instantiated in UCS, templates excluded. They are the owners the IPs of
+// the vNICs are allocated to. Only the vNICs and the templates are
+// resolved, never the rest of the profiles' trees.
+func (c *UCSClient) ServiceProfileVNICs(name string) ([]string, error) {
+	vnics, err := c.ResolveClass("vnicEther", Eq("vnicEther", "name", name), false)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := c.ResolveClass("lsServer", Ne("lsServer", "type", "instance"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	isTemplate := make(map[string]bool, len(templates))
+	for _, template := range templates {
+		isTemplate[template.Dn()] = true
+	}
+
+	dns := make([]string, 0, len(vnics))
+	for _, vnic := range vnics {
+		i := strings.LastIndex(vnic.Dn(), "/")
+		if i < 0 {
+			continue
+		}
+
+		// vNICs also belong to LAN connectivity policies and the like.
+		parent := vnic.Dn()[:i]
+		if isTemplate[parent] || !strings.HasPrefix(parent[strings.LastIndex(parent, "/")+1:], "ls-") {
+			continue
+		}
+		dns = append(dns, vnic.Dn())
+	}
+	return dns, nil
+}
+
 func (c *UCSClient) endpointURL() string {
 	return "https://" + c.ipAddress + "/nuova/"
 }
@@ -3,11 +3,17 @@ package ucsclient
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	utils "github.com/ContainerSolutions/go-utils"
 )
@@ -52,6 +58,45 @@ func (c StubHTTPClientWithAssertion) Post(url string, bodyType string, body io.R
 	return res, nil
 }
 
+// Keeps track of how many requests are in flight at the same time. Logins
+// are answered with loginBody and any other request with Body.
+type StubHTTPClientWithConcurrency struct {
+	Body        []byte
+	LoginBody   []byte
+	inFlight    int32
+	MaxInFlight int32
+	Logins      int32
+}
+
+func (c *StubHTTPClientWithConcurrency) Post(url string, bodyType string, body io.Reader) (*http.Response, error) {
+	n := atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&c.MaxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&c.MaxInFlight, max, n) {
+			break
+		}
+	}
+
+	payload, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewReader(c.Body)),
+	}
+	if strings.HasPrefix(string(payload), "<aaaLogin ") {
+		atomic.AddInt32(&c.Logins, 1)
+		res.Body = ioutil.NopCloser(bytes.NewReader(c.LoginBody))
+	}
+
+	// Give other requests the chance to pile up.
+	time.Sleep(5 * time.Millisecond)
+	return res, nil
+}
+
 func newTestConfig() *Config {
 	return &Config{
 		IpAddress:             "1.2.3.4",
@@ -436,3 +481,543 @@ func TestConfigResolveDN(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveClass(t *testing.T) {
+	pex := []byte(`<configResolveClass cookie="chipsahoy!" inHierarchical="false" classId="macpoolAddr"><inFilter><eq class="macpoolAddr" property="assigned" value="yes"></eq></inFilter></configResolveClass>`)
+	res, err := ioutil.ReadFile("testdata/config-resolve-class-res.xml")
+	utils.FailOnError(t, err)
+
+	config := newTestConfig()
+	ucsClient := NewUCSClient(config)
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClientWithAssertion{
+		StatusCode:      200,
+		Body:            res,
+		ExpectedPayload: pex,
+		t:               t,
+	}
+
+	objects, err := ucsClient.ResolveClass("macpoolAddr", Eq("macpoolAddr", "assigned", "yes"), false)
+	utils.FailOnError(t, err)
+
+	if len(objects) != 4 {
+		t.Fatalf("4 objects expected; got %d", len(objects))
+	}
+
+	mo := objects[0]
+	if mo.ClassId != "macpoolAddr" {
+		t.Errorf("%s expected; got %s", "macpoolAddr", mo.ClassId)
+	}
+
+	if dn := "mac/00:25:B5:00:00:8F"; mo.Dn() != dn {
+		t.Errorf("%s expected; got %s", dn, mo.Dn())
+	}
+
+	if dn := "org-root/ls-deathstar/ether-eth1"; mo.Get("assignedToDn") != dn {
+		t.Errorf("%s expected; got %s", dn, mo.Get("assignedToDn"))
+	}
+}
+
+func TestResolveClassWithoutFilter(t *testing.T) {
+	pex := []byte(`<configResolveClass cookie="chipsahoy!" inHierarchical="true" classId="lsServer"></configResolveClass>`)
+	body := []byte(`<configResolveClass cookie="chipsahoy!" response="yes" classId="lsServer"><outConfigs><lsServer dn="org-root/ls-foo" name="foo"><lsPower rn="power" state="up"/></lsServer></outConfigs></configResolveClass>`)
+	config := newTestConfig()
+	ucsClient := NewUCSClient(config)
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClientWithAssertion{
+		StatusCode:      200,
+		Body:            body,
+		ExpectedPayload: pex,
+		t:               t,
+	}
+
+	objects, err := ucsClient.ResolveClass("lsServer", nil, true)
+	utils.FailOnError(t, err)
+
+	if len(objects) != 1 {
+		t.Fatalf("1 object expected; got %d", len(objects))
+	}
+
+	power := objects[0].ChildrenByClass("lsPower")
+	if len(power) != 1 {
+		t.Fatalf("1 lsPower child expected; got %d", len(power))
+	}
+
+	if power[0].Get("state") != "up" {
+		t.Errorf("%s expected; got %s", "up", power[0].Get("state"))
+	}
+}
+
+func TestConfigConfMos(t *testing.T) {
+	pex := []byte(`<configConfMos cookie="chipsahoy!" inHierarchical="false"><inConfigs><pair key="org-root/ls-bar"><lsServer dn="org-root/ls-bar" status="deleted"></lsServer></pair><pair key="org-root/ls-foo"><lsServer descr="the foo server" dn="org-root/ls-foo" status="modified"><lsPower dn="org-root/ls-foo/power" state="down"></lsPower></lsServer></pair></inConfigs></configConfMos>`)
+	body := []byte(`<configConfMos cookie="chipsahoy!" response="yes"><outConfigs><pair key="org-root/ls-foo"><lsServer descr="the foo server" dn="org-root/ls-foo" name="foo" status="modified"><lsPower dn="org-root/ls-foo/power" state="down"/></lsServer></pair><pair key="org-root/ls-bar"><lsServer dn="org-root/ls-bar" name="bar" status="deleted"/></pair></outConfigs></configConfMos>`)
+	config := newTestConfig()
+	ucsClient := NewUCSClient(config)
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClientWithAssertion{
+		StatusCode:      200,
+		Body:            body,
+		ExpectedPayload: pex,
+		t:               t,
+	}
+
+	configs := map[string]*ManagedObject{
+		"org-root/ls-foo": NewLsServer("org-root/ls-foo").
+			Set("descr", "the foo server").
+			SetStatus(StatusModified).
+			AddChild(NewLsPower("org-root/ls-foo", "down")),
+		"org-root/ls-bar": NewLsServer("org-root/ls-bar").SetStatus(StatusDeleted),
+	}
+
+	objects, err := ucsClient.ConfigConfMos(configs, false)
+	utils.FailOnError(t, err)
+
+	if len(objects) != 2 {
+		t.Fatalf("2 objects expected; got %d", len(objects))
+	}
+
+	foo, ok := objects["org-root/ls-foo"]
+	if !ok {
+		t.Fatalf("org-root/ls-foo expected in %v", objects)
+	}
+
+	if foo.Get("name") != "foo" {
+		t.Errorf("%s expected; got %s", "foo", foo.Get("name"))
+	}
+
+	power := foo.ChildrenByClass("lsPower")
+	if len(power) != 1 || power[0].Get("state") != "down" {
+		t.Errorf("lsPower child with state down expected; got %v", power)
+	}
+
+	if status := objects["org-root/ls-bar"].Get("status"); status != StatusDeleted {
+		t.Errorf("%s expected; got %s", StatusDeleted, status)
+	}
+}
+
+func TestPostWithAPIError(t *testing.T) {
+	tests := []struct {
+		Payload  []byte
+		Body     []byte
+		Expected APIError
+	}{
+		{
+			[]byte(`<aaaLogin inName="john" inPassword="wrong"></aaaLogin>`),
+			[]byte(`<aaaLogin cookie="" response="yes" errorCode="551" invocationResult="unidentified-fail" errorDescr="Authentication failed"> </aaaLogin>`),
+			APIError{Code: "551", Description: "Authentication failed", InvocationResult: "unidentified-fail", Method: "aaaLogin"},
+		},
+		{
+			[]byte(`<lsInstantiateNNamedTemplate cookie="chipsahoy!" dn="org-root/ls-nope" inTargetOrg="org-root" inHierarchical="false" inErrorOnExisting="true"><inNameSet><dn value="deathstar"></dn></inNameSet></lsInstantiateNNamedTemplate>`),
+			[]byte(`<lsInstantiateNNamedTemplate dn="org-root/ls-nope" cookie="chipsahoy!" response="yes" errorCode="103" invocationResult="unidentified-fail" errorDescr="can&apos;t find template org-root/ls-nope"> </lsInstantiateNNamedTemplate>`),
+			APIError{Code: "103", Description: "can't find template org-root/ls-nope", InvocationResult: "unidentified-fail", Method: "lsInstantiateNNamedTemplate", Dn: "org-root/ls-nope"},
+		},
+		{
+			[]byte(`<configResolveDn cookie="chipsahoy!" dn="org-root/ls-foo" inHierarchical="true" />`),
+			[]byte(`<configResolveDn cookie="chipsahoy!" response="yes" errorCode="552" invocationResult="unidentified-fail" errorDescr="Authorization required"> </configResolveDn>`),
+			APIError{Code: "552", Description: "Authorization required", InvocationResult: "unidentified-fail", Method: "configResolveDn", Dn: "org-root/ls-foo"},
+		},
+	}
+	config := newTestConfig()
+	ucsClient := NewUCSClient(config)
+
+	for _, test := range tests {
+		ucsClient.httpClient = StubHTTPClient{
+			StatusCode: 200,
+			Body:       test.Body,
+		}
+
+		res, err := ucsClient.Post(test.Payload)
+		if len(res) > 0 {
+			t.Errorf("expected blank response but got %s", res)
+		}
+
+		apiErr, ok := err.(*APIError)
+		if !ok {
+			t.Fatalf("*APIError expected; got %#v", err)
+		}
+
+		if *apiErr != test.Expected {
+			t.Errorf("%#v expected; got %#v", test.Expected, *apiErr)
+		}
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	err := &APIError{
+		Code:             "103",
+		Description:      "can't find template org-root/ls-nope",
+		InvocationResult: "unidentified-fail",
+		Method:           "lsInstantiateNNamedTemplate",
+		Dn:               "org-root/ls-nope",
+	}
+	expected := "UCS lsInstantiateNNamedTemplate failed on org-root/ls-nope: error 103 (unidentified-fail): can't find template org-root/ls-nope"
+	if err.Error() != expected {
+		t.Errorf("%s expected; got %s", expected, err.Error())
+	}
+}
+
+func TestCreateServiceProfileWithAPIError(t *testing.T) {
+	body := []byte(`<lsInstantiateNNamedTemplate dn="org-root/ls-test-template" cookie="chipsahoy!" response="yes" errorCode="104" invocationResult="unidentified-fail" errorDescr="Creation of object failed: profile already exists"> </lsInstantiateNNamedTemplate>`)
+	config := newTestConfig()
+	ucsClient := NewUCSClient(config)
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClient{
+		StatusCode: 200,
+		Body:       body,
+	}
+	sp := &ServiceProfile{
+		Name:      "deathstar",
+		Template:  "test-template",
+		TargetOrg: "org-root",
+	}
+	created, err := ucsClient.CreateServiceProfile(sp)
+	if created {
+		t.Error("expected false but got true")
+	}
+
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Code != "104" {
+		t.Errorf("*APIError with code 104 expected; got %#v", err)
+	}
+}
+
+func TestConcurrentCreateServiceProfile(t *testing.T) {
+	body, err := ioutil.ReadFile("testdata/service-profile.xml")
+	utils.FailOnError(t, err)
+
+	config := newTestConfig()
+	config.MaxConcurrentRequests = 3
+	ucsClient := NewUCSClient(config)
+	stub := &StubHTTPClientWithConcurrency{
+		Body:      body,
+		LoginBody: []byte(`<aaaLogin cookie="" response="yes" outCookie="chipsahoy!" outRefreshPeriod="600"></aaaLogin>`),
+	}
+	ucsClient.httpClient = stub
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sp := &ServiceProfile{
+				Name:      fmt.Sprintf("deathstar-%d", i),
+				Template:  "test-template",
+				TargetOrg: "org-root",
+			}
+			created, err := ucsClient.CreateServiceProfile(sp)
+			if err == nil && !created {
+				err = fmt.Errorf("%s was not created", sp.Name)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if stub.Logins != 1 {
+		t.Errorf("1 login expected; got %d", stub.Logins)
+	}
+
+	if stub.MaxInFlight > int32(config.MaxConcurrentRequests) {
+		t.Errorf("no more than %d requests in flight expected; got %d", config.MaxConcurrentRequests, stub.MaxInFlight)
+	}
+
+	if stub.MaxInFlight < 2 {
+		t.Errorf("requests expected to run concurrently; got %d in flight at most", stub.MaxInFlight)
+	}
+}
+
+func TestConfigResolveDNWithoutOrg(t *testing.T) {
+	res, err := ioutil.ReadFile("testdata/config-resolve-dn-res.xml")
+	utils.FailOnError(t, err)
+
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClient{
+		StatusCode: 200,
+		Body:       res,
+	}
+
+	if _, err := ucsClient.ConfigResolveDN("ls-foobar"); err == nil {
+		t.Errorf("error expected for %s; got nil", "ls-foobar")
+	}
+}
+
+func TestConfigResolveDNStatus(t *testing.T) {
+	res, err := ioutil.ReadFile("testdata/config-resolve-dn-res.xml")
+	utils.FailOnError(t, err)
+
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClient{
+		StatusCode: 200,
+		Body:       res,
+	}
+
+	sp, err := ucsClient.ConfigResolveDN("org-root/ls-foobar")
+	utils.FailOnError(t, err)
+
+	if sp.AssocState != "unassociated" {
+		t.Errorf("%s expected; got %s", "unassociated", sp.AssocState)
+	}
+
+	if sp.ConfigState != CONFIG_STATE_FAILED {
+		t.Errorf("%s expected; got %s", CONFIG_STATE_FAILED, sp.ConfigState)
+	}
+
+	if sp.OperState != OPER_STATE_CONFIG_FAILURE {
+		t.Errorf("%s expected; got %s", OPER_STATE_CONFIG_FAILURE, sp.OperState)
+	}
+
+	if sp.FsmStatus != FSM_STATUS_IN_PROGRESS {
+		t.Errorf("%s expected; got %s", FSM_STATUS_IN_PROGRESS, sp.FsmStatus)
+	}
+
+	expectedIssues := map[string]string{
+		"server": "insufficient-resources,soft-pinning-vlan-mismatch",
+		"vnic":   "wwpn-derivation-virtualized-port",
+	}
+	if len(sp.ConfigIssues) != len(expectedIssues) {
+		t.Errorf("%v expected; got %v", expectedIssues, sp.ConfigIssues)
+	}
+	for area, issue := range expectedIssues {
+		if sp.ConfigIssues[area] != issue {
+			t.Errorf("%s expected; got %s", issue, sp.ConfigIssues[area])
+		}
+	}
+
+	expectedFaults := []Fault{
+		{Dn: "org-root/ls-foobar/fault-F4525248", Code: "F4525248", Severity: "warning"},
+		{Dn: "org-root/ls-foobar/fault-F0327", Code: "F0327", Severity: "major"},
+		{Dn: "org-root/ls-foobar/fc-fc1/fault-F0170", Code: "F0170", Severity: "minor"},
+		{Dn: "org-root/ls-foobar/fc-fc0/fault-F0170", Code: "F0170", Severity: "minor"},
+	}
+	if len(sp.Faults) != len(expectedFaults) {
+		t.Fatalf("%d faults expected; got %d", len(expectedFaults), len(sp.Faults))
+	}
+	for i, f := range expectedFaults {
+		actual := sp.Faults[i]
+		if actual.Dn != f.Dn || actual.Code != f.Code || actual.Severity != f.Severity {
+			t.Errorf("%+v expected; got %+v", f, actual)
+		}
+	}
+
+	if cause := "configuration-failure"; sp.Faults[1].Cause != cause {
+		t.Errorf("%s expected; got %s", cause, sp.Faults[1].Cause)
+	}
+
+	if sp.PowerState != POWER_STATE_UP {
+		t.Errorf("%s expected; got %s", POWER_STATE_UP, sp.PowerState)
+	}
+}
+
+func TestServiceProfileState(t *testing.T) {
+	tests := []struct {
+		SP     ServiceProfile
+		Busy   bool
+		Ready  bool
+		Failed bool
+	}{
+		{ServiceProfile{AssocState: "unassociated", ConfigState: CONFIG_STATE_FAILED, FsmStatus: FSM_STATUS_IN_PROGRESS}, true, false, false},
+		{ServiceProfile{AssocState: "associating", ConfigState: CONFIG_STATE_APPLYING, FsmStatus: "nop"}, true, false, false},
+		{ServiceProfile{AssocState: ASSOC_STATE_ASSOCIATED, ConfigState: CONFIG_STATE_APPLIED, OperState: "ok", FsmStatus: "success"}, false, true, false},
+		{ServiceProfile{AssocState: "unassociated", ConfigState: CONFIG_STATE_FAILED, OperState: OPER_STATE_CONFIG_FAILURE, FsmStatus: "success"}, false, false, true},
+		{ServiceProfile{AssocState: "unassociated", ConfigState: "not-applied", FsmStatus: FSM_STATUS_FAIL}, false, false, true},
+		{ServiceProfile{AssocState: "unassociated", ConfigState: "not-applied", FsmStatus: "nop"}, false, false, false},
+	}
+
+	for _, test := range tests {
+		if test.SP.Busy() != test.Busy {
+			t.Errorf("Busy() = %v expected for %+v", test.Busy, test.SP)
+		}
+
+		if test.SP.Ready() != test.Ready {
+			t.Errorf("Ready() = %v expected for %+v", test.Ready, test.SP)
+		}
+
+		if test.SP.Failed() != test.Failed {
+			t.Errorf("Failed() = %v expected for %+v", test.Failed, test.SP)
+		}
+	}
+}
+
+func TestSetPowerState(t *testing.T) {
+	pex := []byte(`<configConfMos cookie="chipsahoy!" inHierarchical="false"><inConfigs><pair key="org-root/ls-foobar/power"><lsPower dn="org-root/ls-foobar/power" state="down" status="modified"></lsPower></pair></inConfigs></configConfMos>`)
+	body := []byte(`<configConfMos cookie="chipsahoy!" response="yes"><outConfigs><pair key="org-root/ls-foobar/power"><lsPower dn="org-root/ls-foobar/power" state="down" status="modified"/></pair></outConfigs></configConfMos>`)
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClientWithAssertion{
+		StatusCode:      200,
+		Body:            body,
+		ExpectedPayload: pex,
+		t:               t,
+	}
+
+	err := ucsClient.SetPowerState("org-root/ls-foobar", POWER_STATE_DOWN)
+	utils.FailOnError(t, err)
+}
+
+func TestSetServiceProfileTemplate(t *testing.T) {
+	tests := []struct {
+		Template string
+		Payload  string
+	}{
+		{"bar-template", `<configConfMos cookie="chipsahoy!" inHierarchical="false"><inConfigs><pair key="org-root/ls-foobar"><lsServer dn="org-root/ls-foobar" srcTemplName="bar-template" status="modified"></lsServer></pair></inConfigs></configConfMos>`},
+		{"", `<configConfMos cookie="chipsahoy!" inHierarchical="false"><inConfigs><pair key="org-root/ls-foobar"><lsServer dn="org-root/ls-foobar" srcTemplName="" status="modified"></lsServer></pair></inConfigs></configConfMos>`},
+	}
+
+	for _, test := range tests {
+		ucsClient := NewUCSClient(newTestConfig())
+		ucsClient.cookie = "chipsahoy!"
+		ucsClient.httpClient = StubHTTPClientWithAssertion{
+			StatusCode:      200,
+			Body:            []byte(`<configConfMos cookie="chipsahoy!" response="yes"><outConfigs></outConfigs></configConfMos>`),
+			ExpectedPayload: []byte(test.Payload),
+			t:               t,
+		}
+
+		err := ucsClient.SetServiceProfileTemplate("org-root/ls-foobar", test.Template)
+		utils.FailOnError(t, err)
+	}
+}
+
+func TestConfigConfRename(t *testing.T) {
+	pex := []byte(`<configConfRename cookie="chipsahoy!" dn="org-root/org-a/ls-foo" inNewName="bar" inHierarchical="false"></configConfRename>`)
+	body := []byte(`<configConfRename dn="org-root/org-a/ls-foo" cookie="chipsahoy!" response="yes"><outConfig><lsServer dn="org-root/org-a/ls-bar" name="bar" status="modified"/></outConfig></configConfRename>`)
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClientWithAssertion{
+		StatusCode:      200,
+		Body:            body,
+		ExpectedPayload: pex,
+		t:               t,
+	}
+
+	mo, err := ucsClient.ConfigConfRename("org-root/org-a/ls-foo", "bar", false)
+	utils.FailOnError(t, err)
+
+	if dn := "org-root/org-a/ls-bar"; mo.Dn() != dn {
+		t.Errorf("%s expected; got %s", dn, mo.Dn())
+	}
+}
+
+func TestConfigResolveDNNestedOrg(t *testing.T) {
+	dn := "org-root/org-a/org-b/ls-x"
+	body := []byte(`<configResolveDn dn="org-root/org-a/org-b/ls-x" cookie="chipsahoy!" response="yes"><outConfig><lsServer dn="org-root/org-a/org-b/ls-x" name="x" srcTemplName="mamamia"/></outConfig></configResolveDn>`)
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClient{
+		StatusCode: 200,
+		Body:       body,
+	}
+
+	sp, err := ucsClient.ConfigResolveDN(dn)
+	utils.FailOnError(t, err)
+
+	if targetOrg := "org-root/org-a/org-b"; sp.TargetOrg != targetOrg {
+		t.Errorf("%s expected; got %s", targetOrg, sp.TargetOrg)
+	}
+
+	if sp.DN() != dn {
+		t.Errorf("%s expected; got %s", dn, sp.DN())
+	}
+}
+
+func TestConfigResolveDNNotFound(t *testing.T) {
+	body := []byte(`<configResolveDn dn="org-root/ls-nope" cookie="chipsahoy!" response="yes"><outConfig></outConfig></configResolveDn>`)
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClient{
+		StatusCode: 200,
+		Body:       body,
+	}
+
+	sp, err := ucsClient.ConfigResolveDN("org-root/ls-nope")
+	utils.FailOnError(t, err)
+
+	if sp != nil {
+		t.Errorf("nil expected; got %v", sp)
+	}
+}
+
+func TestServiceProfileVNICs(t *testing.T) {
+	stub := &StubHTTPClientWithSequence{
+		Bodies: [][]byte{
+			[]byte(`<configResolveClass cookie="chipsahoy!" response="yes" classId="vnicEther"><outConfigs>` +
+				`<vnicEther dn="org-root/ls-foo/ether-eth1" name="eth1"/>` +
+				`<vnicEther dn="org-root/org-a/ls-bar/ether-eth1" name="eth1"/>` +
+				`<vnicEther dn="org-root/ls-tmpl/ether-eth1" name="eth1"/>` +
+				`<vnicEther dn="org-root/lan-conn-pol-web/ether-eth1" name="eth1"/>` +
+				`</outConfigs></configResolveClass>`),
+			[]byte(`<configResolveClass cookie="chipsahoy!" response="yes" classId="lsServer"><outConfigs>` +
+				`<lsServer dn="org-root/ls-tmpl" name="tmpl" type="updating-template"/>` +
+				`</outConfigs></configResolveClass>`),
+		},
+	}
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = stub
+
+	dns, err := ucsClient.ServiceProfileVNICs("eth1")
+	utils.FailOnError(t, err)
+
+	expected := []string{"org-root/ls-foo/ether-eth1", "org-root/org-a/ls-bar/ether-eth1"}
+	if !reflect.DeepEqual(dns, expected) {
+		t.Errorf("%v expected; got %v", expected, dns)
+	}
+
+	payloads := []string{
+		`<configResolveClass cookie="chipsahoy!" inHierarchical="false" classId="vnicEther"><inFilter><eq class="vnicEther" property="name" value="eth1"></eq></inFilter></configResolveClass>`,
+		`<configResolveClass cookie="chipsahoy!" inHierarchical="false" classId="lsServer"><inFilter><ne class="lsServer" property="type" value="instance"></ne></inFilter></configResolveClass>`,
+	}
+	if !reflect.DeepEqual(stub.Payloads, payloads) {
+		t.Errorf("%v expected; got %v", payloads, stub.Payloads)
+	}
+}
+
+func TestResolveDn(t *testing.T) {
+	pex := []byte(`<configResolveDn cookie="chipsahoy!" dn="org-root/org-a" inHierarchical="false"></configResolveDn>`)
+	body := []byte(`<configResolveDn dn="org-root/org-a" cookie="chipsahoy!" response="yes"><outConfig><orgOrg dn="org-root/org-a" name="a" descr="tenant a"/></outConfig></configResolveDn>`)
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClientWithAssertion{
+		StatusCode:      200,
+		Body:            body,
+		ExpectedPayload: pex,
+		t:               t,
+	}
+
+	mo, err := ucsClient.ResolveDn("org-root/org-a", false)
+	utils.FailOnError(t, err)
+
+	if mo == nil {
+		t.Fatalf("orgOrg expected; got nil")
+	}
+
+	if descr := mo.Get("descr"); descr != "tenant a" {
+		t.Errorf("%s expected; got %s", "tenant a", descr)
+	}
+}
+
+func TestResolveDnNotFound(t *testing.T) {
+	body := []byte(`<configResolveDn dn="org-root/org-a" cookie="chipsahoy!" response="yes"><outConfig></outConfig></configResolveDn>`)
+	ucsClient := NewUCSClient(newTestConfig())
+	ucsClient.cookie = "chipsahoy!"
+	ucsClient.httpClient = StubHTTPClient{
+		StatusCode: 200,
+		Body:       body,
+	}
+
+	mo, err := ucsClient.ResolveDn("org-root/org-a", false)
+	utils.FailOnError(t, err)
+
+	if mo != nil {
+		t.Errorf("nil expected; got %v", mo)
+	}
+}
@@ -8,16 +8,90 @@ import (
 const STATUS_DELETED = "deleted"
 
 type (
+	// ConfigConfMosRequest creates, modifies or deletes any number of managed
+	// objects in a single transaction. Each pair is keyed by the object's DN.
+	ConfigConfMosRequest struct {
+		XMLName        xml.Name     `xml:"configConfMos"`
+		Cookie         string       `xml:"cookie,attr"`
+		InHierarchical bool         `xml:"inHierarchical,attr"`
+		InConfigs      []ConfigPair `xml:"inConfigs>pair"`
+	}
+
+	ConfigConfMosResponse struct {
+		XMLName    xml.Name     `xml:"configConfMos"`
+		Cookie     string       `xml:"cookie,attr"`
+		Response   string       `xml:"response,attr"`
+		OutConfigs []ConfigPair `xml:"outConfigs>pair"`
+	}
+
+	// ConfigConfRenameRequest changes the name (and therefore the DN) of a
+	// managed object in place.
+	ConfigConfRenameRequest struct {
+		XMLName        xml.Name `xml:"configConfRename"`
+		Cookie         string   `xml:"cookie,attr"`
+		Dn             string   `xml:"dn,attr"`
+		InNewName      string   `xml:"inNewName,attr"`
+		InHierarchical bool     `xml:"inHierarchical,attr"`
+	}
+
+	ConfigConfRenameResponse struct {
+		XMLName   xml.Name       `xml:"configConfRename"`
+		Cookie    string         `xml:"cookie,attr"`
+		Dn        string         `xml:"dn,attr"`
+		Response  string         `xml:"response,attr"`
+		OutConfig ManagedObjects `xml:"outConfig"`
+	}
+
+	ConfigPair struct {
+		Key    string        `xml:"key,attr"`
+		Object ManagedObject `xml:",any"`
+	}
+
 	ConfigResolveClass struct {
 		XMLName    xml.Name   `xml:"configResolveClass"`
 		OutConfigs OutConfigs `xml:"outConfigs"`
 	}
 
+	// ConfigResolveClassRequest queries every managed object of a given class,
+	// optionally narrowed down by a filter.
+	ConfigResolveClassRequest struct {
+		XMLName        xml.Name  `xml:"configResolveClass"`
+		Cookie         string    `xml:"cookie,attr"`
+		InHierarchical bool      `xml:"inHierarchical,attr"`
+		ClassId        string    `xml:"classId,attr"`
+		InFilter       *InFilter `xml:"inFilter,omitempty"`
+	}
+
+	ConfigResolveClassResponse struct {
+		XMLName    xml.Name       `xml:"configResolveClass"`
+		Cookie     string         `xml:"cookie,attr"`
+		ClassId    string         `xml:"classId,attr"`
+		Response   string         `xml:"response,attr"`
+		OutConfigs ManagedObjects `xml:"outConfigs"`
+	}
+
 	ConfigResolveDn struct {
 		XMLName   xml.Name  `xml:"configResolveDn"`
 		OutConfig OutConfig `xml:"outConfig"`
 	}
 
+	// ConfigResolveDnRequest queries a single managed object of any class by
+	// its DN.
+	ConfigResolveDnRequest struct {
+		XMLName        xml.Name `xml:"configResolveDn"`
+		Cookie         string   `xml:"cookie,attr"`
+		Dn             string   `xml:"dn,attr"`
+		InHierarchical bool     `xml:"inHierarchical,attr"`
+	}
+
+	ConfigResolveDnResponse struct {
+		XMLName   xml.Name       `xml:"configResolveDn"`
+		Cookie    string         `xml:"cookie,attr"`
+		Dn        string         `xml:"dn,attr"`
+		Response  string         `xml:"response,attr"`
+		OutConfig ManagedObjects `xml:"outConfig"`
+	}
+
 	ConfigScope struct {
 		XMLName    xml.Name `xml:"configScope"`
 		OutConfigs OutConfigs
@@ -51,12 +125,37 @@ type (
 		Value   string   `xml:"value,attr"`
 	}
 
+	// FaultInst is a fault raised by UCS against the managed object it is a
+	// child of.
+	FaultInst struct {
+		Code     string `xml:"code,attr"`
+		Severity string `xml:"severity,attr"`
+		Cause    string `xml:"cause,attr"`
+		Descr    string `xml:"descr,attr"`
+		Created  string `xml:"created,attr"`
+		Rn       string `xml:"rn,attr"`
+	}
+
+	// FilterNode is a single element of an inFilter tree. Property filters
+	// (eq, ne, wcard...) carry their class, property and value as attributes
+	// whereas logical filters (and, or, not) only carry children.
+	FilterNode struct {
+		XMLName  xml.Name
+		Attrs    []xml.Attr `xml:",any,attr"`
+		Children []FilterNode
+	}
+
 	InConfig struct {
 		XMLName xml.Name `xml:"inConfigs`
 		Power   LsPower  `xml:"lsPower,omitempty"`
 		Pair    Pair
 	}
 
+	InFilter struct {
+		XMLName xml.Name `xml:"inFilter"`
+		Filter  FilterNode
+	}
+
 	InNameSet struct {
 		XMLName xml.Name `xml:"inNameSet"`
 		Dn      Dn
@@ -76,11 +175,23 @@ type (
 	// LogingResponse maps the XML response document returned by UCS when
 	// calling the login method.
 	LoginResponse struct {
-		XMLName    xml.Name `xml:"aaaLogin"`
-		Cookie     string   `xml:"cookie,attr"`
-		Response   string   `xml:"response,attr"`
-		OutCookie  string   `xml:"outCookie,attr"`
-		OutDomains string   `xml:"outDomains,attr"`
+		XMLName          xml.Name `xml:"aaaLogin"`
+		Cookie           string   `xml:"cookie,attr"`
+		Response         string   `xml:"response,attr"`
+		OutCookie        string   `xml:"outCookie,attr"`
+		OutRefreshPeriod int      `xml:"outRefreshPeriod,attr"`
+		OutDomains       string   `xml:"outDomains,attr"`
+	}
+
+	// LsIssues lists, per area, the reasons why a service profile could
+	// not be configured.
+	LsIssues struct {
+		ConfigWarnings      string `xml:"configWarnings,attr"`
+		IscsiConfigIssues   string `xml:"iscsiConfigIssues,attr"`
+		NetworkConfigIssues string `xml:"networkConfigIssues,attr"`
+		ServerConfigIssues  string `xml:"serverConfigIssues,attr"`
+		StorageConfigIssues string `xml:"storageConfigIssues,attr"`
+		VnicConfigIssues    string `xml:"vnicConfigIssues,attr"`
 	}
 
 	LsPower struct {
@@ -88,6 +199,16 @@ type (
 		State string `xml:"state,attr"`
 	}
 
+	// LsServerFsm holds the state of the finite state machine UCS runs to
+	// configure or associate a service profile.
+	LsServerFsm struct {
+		CurrentFsm  string `xml:"currentFsm,attr"`
+		FsmStatus   string `xml:"fsmStatus,attr"`
+		Progress    string `xml:"progress,attr"`
+		RmtErrCode  string `xml:"rmtErrCode,attr"`
+		RmtErrDescr string `xml:"rmtErrDescr,attr"`
+	}
+
 	MACPoolAddr struct {
 		XMLName  xml.Name `xml:"macpoolAddr"`
 		Id       string   `xml:"id,attr"`
@@ -95,6 +216,31 @@ type (
 		DN       string   `xml:"assignedToDn,attr"`
 	}
 
+	// ManagedObject maps any element of the UCS management information tree
+	// regardless of its class, keeping all its attributes and children.
+	ManagedObject struct {
+		XMLName  xml.Name
+		Attrs    []xml.Attr      `xml:",any,attr"`
+		Children []ManagedObject `xml:",any"`
+	}
+
+	ManagedObjects struct {
+		Objects []ManagedObject `xml:",any"`
+	}
+
+	// MethodResponse maps the attributes shared by the responses of every
+	// UCS XML API method, regardless of its outcome. Failed calls carry an
+	// errorCode, an errorDescr and an invocationResult.
+	MethodResponse struct {
+		XMLName          xml.Name
+		Cookie           string `xml:"cookie,attr"`
+		Dn               string `xml:"dn,attr"`
+		Response         string `xml:"response,attr"`
+		InvocationResult string `xml:"invocationResult,attr"`
+		ErrorCode        string `xml:"errorCode,attr"`
+		ErrorDescr       string `xml:"errorDescr,attr"`
+	}
+
 	OutConfig struct {
 		XMLName      xml.Name       `xml:"outConfig"`
 		ServerConfig []ServerConfig `xml:"lsServer"`
@@ -111,13 +257,39 @@ type (
 		ServerConfig ServerConfig `xml:",omitempty"`
 	}
 
+	// RefreshRequest asks UCS for a new cookie before the current one
+	// expires, without having to log in again.
+	RefreshRequest struct {
+		XMLName  xml.Name `xml:"aaaRefresh"`
+		Username string   `xml:"inName,attr"`
+		Password string   `xml:"inPassword,attr"`
+		Cookie   string   `xml:"inCookie,attr"`
+	}
+
+	RefreshResponse struct {
+		XMLName          xml.Name `xml:"aaaRefresh"`
+		Cookie           string   `xml:"cookie,attr"`
+		Response         string   `xml:"response,attr"`
+		OutCookie        string   `xml:"outCookie,attr"`
+		OutRefreshPeriod int      `xml:"outRefreshPeriod,attr"`
+		OutDomains       string   `xml:"outDomains,attr"`
+	}
+
 	ServerConfig struct {
-		XMLName   xml.Name    `xml:"lsServer"`
-		Dn        string      `xml:"dn,attr"`
-		Name      string      `xml:"name,attr"`
-		SrcTempl  string      `xml:"srcTemplName,attr"`
-		Status    string      `xml:"status,attr"`
-		VnicEther []VnicEther `xml:"vnicEther"`
+		XMLName     xml.Name     `xml:"lsServer"`
+		Dn          string       `xml:"dn,attr"`
+		Name        string       `xml:"name,attr"`
+		SrcTempl    string       `xml:"srcTemplName,attr"`
+		Status      string       `xml:"status,attr"`
+		AssocState  string       `xml:"assocState,attr,omitempty"`
+		ConfigState string       `xml:"configState,attr,omitempty"`
+		OperState   string       `xml:"operState,attr,omitempty"`
+		VnicEther   []VnicEther  `xml:"vnicEther"`
+		VnicFc      []VnicFc     `xml:"vnicFc"`
+		Faults      []FaultInst  `xml:"faultInst"`
+		Issues      *LsIssues    `xml:"lsIssues"`
+		Fsm         *LsServerFsm `xml:"lsServerFsm"`
+		Power       *LsPower     `xml:"lsPower"`
 	}
 
 	ServiceProfileRequest struct {
@@ -142,10 +314,17 @@ type (
 	}
 
 	VnicEther struct {
-		Addr          string `xml:"addr,attr"`
-		IdentPoolName string `xml:"identPoolName,attr"`
-		Name          string `xml:"name,attr"`
-		NwTemplName   string `xml:"nwTemplName,attr"`
+		Addr          string      `xml:"addr,attr"`
+		IdentPoolName string      `xml:"identPoolName,attr"`
+		Name          string      `xml:"name,attr"`
+		NwTemplName   string      `xml:"nwTemplName,attr"`
+		Faults        []FaultInst `xml:"faultInst"`
+	}
+
+	VnicFc struct {
+		Addr   string      `xml:"addr,attr"`
+		Name   string      `xml:"name,attr"`
+		Faults []FaultInst `xml:"faultInst"`
 	}
 
 	XMLDestroyRequest struct {
@@ -170,6 +349,36 @@ func (req *LogoutRequest) Marshal() ([]byte, error) {
 	return xml.Marshal(req)
 }
 
+// Converts a RefreshRequest struct into a plain-text XML string ready to be
+// delivered to the UCS server.
+func (req *RefreshRequest) Marshal() ([]byte, error) {
+	return xml.Marshal(req)
+}
+
+// Converts a ConfigConfMosRequest into a plain-text XML string ready
+// to be delivered to the UCS server.
+func (req *ConfigConfMosRequest) Marshal() ([]byte, error) {
+	return xml.Marshal(req)
+}
+
+// Converts a ConfigConfRenameRequest into a plain-text XML string ready
+// to be delivered to the UCS server.
+func (req *ConfigConfRenameRequest) Marshal() ([]byte, error) {
+	return xml.Marshal(req)
+}
+
+// Converts a ConfigResolveClassRequest into a plain-text XML string ready
+// to be delivered to the UCS server.
+func (req *ConfigResolveClassRequest) Marshal() ([]byte, error) {
+	return xml.Marshal(req)
+}
+
+// Converts a ConfigResolveDnRequest into a plain-text XML string ready
+// to be delivered to the UCS server.
+func (req *ConfigResolveDnRequest) Marshal() ([]byte, error) {
+	return xml.Marshal(req)
+}
+
 func (req *DestroyRequest) Marshal(cookie string) ([]byte, error) {
 	targetProfile := strings.Join([]string{req.TargetOrg, "/", "ls-", req.Name}, "")
 	doc := XMLDestroyRequest{
@@ -196,8 +405,52 @@ func NewLoginResponse(data []byte) (*LoginResponse, error) {
 	return res, err
 }
 
+// Extracts the new session information from the response to an aaaRefresh
+// request and maps it into a RefreshResponse struct.
+func NewRefreshResponse(data []byte) (*RefreshResponse, error) {
+	res := &RefreshResponse{}
+	err := xml.Unmarshal(data, res)
+	return res, err
+}
+
 func NewServiceProfileResponse(data []byte) (*ServiceProfileResponse, error) {
 	res := &ServiceProfileResponse{}
 	err := xml.Unmarshal(data, &res)
 	return res, err
 }
+
+func NewConfigResolveClassResponse(data []byte) (*ConfigResolveClassResponse, error) {
+	res := &ConfigResolveClassResponse{}
+	err := xml.Unmarshal(data, res)
+	return res, err
+}
+
+func NewConfigConfMosResponse(data []byte) (*ConfigConfMosResponse, error) {
+	res := &ConfigConfMosResponse{}
+	err := xml.Unmarshal(data, res)
+	return res, err
+}
+
+func NewConfigResolveDnResponse(data []byte) (*ConfigResolveDnResponse, error) {
+	res := &ConfigResolveDnResponse{}
+	err := xml.Unmarshal(data, res)
+	return res, err
+}
+
+func NewConfigConfRenameResponse(data []byte) (*ConfigConfRenameResponse, error) {
+	res := &ConfigConfRenameResponse{}
+	err := xml.Unmarshal(data, res)
+	return res, err
+}
+
+// Extracts the method name and outcome of any server response.
+func NewMethodResponse(data []byte) (*MethodResponse, error) {
+	res := &MethodResponse{}
+	err := xml.Unmarshal(data, res)
+	return res, err
+}
+
+// Determines whether UCS reported an error for the method call.
+func (res *MethodResponse) Failed() bool {
+	return res.ErrorCode != "" || res.ErrorDescr != "" || res.InvocationResult != ""
+}
@@ -115,3 +115,196 @@ func TestNewServiceProfileResponse(t *testing.T) {
 		t.Errorf("%s expected; got %s", status, res.OutConfigs.ServerConfig.Status)
 	}
 }
+
+func TestMarshalConfigResolveClassRequest(t *testing.T) {
+	pex := []byte(`<configResolveClass cookie="chipsahoy!" inHierarchical="false" classId="macpoolAddr"></configResolveClass>`)
+	req := ConfigResolveClassRequest{
+		Cookie:  "chipsahoy!",
+		ClassId: "macpoolAddr",
+	}
+	out, err := req.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, pex) {
+		t.Errorf("%s expected; got %s", pex, out)
+	}
+}
+
+func TestNewConfigResolveClassResponse(t *testing.T) {
+	data := []byte(`<configResolveClass cookie="chipsahoy!" response="yes" classId="fabricVlan"><outConfigs><fabricVlan dn="fabric/lan/net-csvlan2009" id="2009" name="csvlan2009"/><fabricVlan dn="fabric/lan/net-csvlan2010" id="2010" name="csvlan2010"/></outConfigs></configResolveClass>`)
+	res, err := NewConfigResolveClassResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ClassId != "fabricVlan" {
+		t.Errorf("%s expected; got %s", "fabricVlan", res.ClassId)
+	}
+
+	if len(res.OutConfigs.Objects) != 2 {
+		t.Fatalf("2 objects expected; got %d", len(res.OutConfigs.Objects))
+	}
+
+	mo := res.OutConfigs.Objects[1]
+	if mo.XMLName.Local != "fabricVlan" {
+		t.Errorf("%s expected; got %s", "fabricVlan", mo.XMLName.Local)
+	}
+
+	if len(mo.Attrs) != 3 {
+		t.Errorf("3 attributes expected; got %d", len(mo.Attrs))
+	}
+}
+
+func TestNewConfigConfMosResponse(t *testing.T) {
+	data := []byte(`<configConfMos cookie="chipsahoy!" response="yes"><outConfigs><pair key="fabric/lan/net-csvlan2009"><fabricVlan dn="fabric/lan/net-csvlan2009" id="2009" name="csvlan2009" status="created"/></pair></outConfigs></configConfMos>`)
+	res, err := NewConfigConfMosResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.OutConfigs) != 1 {
+		t.Fatalf("1 pair expected; got %d", len(res.OutConfigs))
+	}
+
+	pair := res.OutConfigs[0]
+	if key := "fabric/lan/net-csvlan2009"; pair.Key != key {
+		t.Errorf("%s expected; got %s", key, pair.Key)
+	}
+
+	if pair.Object.XMLName.Local != "fabricVlan" {
+		t.Errorf("%s expected; got %s", "fabricVlan", pair.Object.XMLName.Local)
+	}
+}
+
+func TestNewMethodResponse(t *testing.T) {
+	data := []byte(`<configConfMos cookie="chipsahoy!" response="yes" errorCode="103" invocationResult="unidentified-fail" errorDescr="can't create; object already exists."> </configConfMos>`)
+	res, err := NewMethodResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.XMLName.Local != "configConfMos" {
+		t.Errorf("%s expected; got %s", "configConfMos", res.XMLName.Local)
+	}
+
+	if !res.Failed() {
+		t.Errorf("expected response to have failed")
+	}
+
+	if res.ErrorCode != "103" {
+		t.Errorf("%s expected; got %s", "103", res.ErrorCode)
+	}
+
+	data, err = ioutil.ReadFile("testdata/service-profile.xml")
+	utils.FailOnError(t, err)
+
+	res, err = NewMethodResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Failed() {
+		t.Errorf("expected response not to have failed")
+	}
+}
+
+func TestMarshalRefreshRequest(t *testing.T) {
+	ex := []byte(`<aaaRefresh inName="john" inPassword="doesecret" inCookie="chipsahoy!"></aaaRefresh>`)
+	req := RefreshRequest{
+		Username: "john",
+		Password: "doesecret",
+		Cookie:   "chipsahoy!",
+	}
+
+	out, err := req.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, ex) {
+		t.Errorf("%s expected; got %s", ex, out)
+	}
+}
+
+func TestNewRefreshResponse(t *testing.T) {
+	data := []byte(`<aaaRefresh cookie="" response="yes" outCookie="oreo!" outRefreshPeriod="600" outPriv="admin,read-only" outDomains="org-blah" outChannel="noencssl" outEvtChannel="noencssl"></aaaRefresh>`)
+	res, err := NewRefreshResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.OutCookie != "oreo!" {
+		t.Errorf("%s expected; got %s", "oreo!", res.OutCookie)
+	}
+
+	if res.OutRefreshPeriod != 600 {
+		t.Errorf("%d expected; got %d", 600, res.OutRefreshPeriod)
+	}
+}
+
+func TestMarshalConfigConfRenameRequest(t *testing.T) {
+	pex := []byte(`<configConfRename cookie="chipsahoy!" dn="org-root/ls-foo" inNewName="bar" inHierarchical="false"></configConfRename>`)
+	req := ConfigConfRenameRequest{
+		Cookie:    "chipsahoy!",
+		Dn:        "org-root/ls-foo",
+		InNewName: "bar",
+	}
+	out, err := req.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, pex) {
+		t.Errorf("%s expected; got %s", pex, out)
+	}
+}
+
+func TestNewConfigConfRenameResponse(t *testing.T) {
+	data := []byte(`<configConfRename dn="org-root/ls-foo" cookie="chipsahoy!" response="yes"><outConfig><lsServer dn="org-root/ls-bar" name="bar" status="modified"/></outConfig></configConfRename>`)
+	res, err := NewConfigConfRenameResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.OutConfig.Objects) != 1 {
+		t.Fatalf("1 object expected; got %d", len(res.OutConfig.Objects))
+	}
+
+	if mo := res.OutConfig.Objects[0]; mo.XMLName.Local != "lsServer" {
+		t.Errorf("%s expected; got %s", "lsServer", mo.XMLName.Local)
+	}
+}
+
+func TestMarshalConfigResolveDnRequest(t *testing.T) {
+	pex := []byte(`<configResolveDn cookie="chipsahoy!" dn="org-root/org-a" inHierarchical="false"></configResolveDn>`)
+	req := ConfigResolveDnRequest{
+		Cookie: "chipsahoy!",
+		Dn:     "org-root/org-a",
+	}
+	out, err := req.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, pex) {
+		t.Errorf("%s expected; got %s", pex, out)
+	}
+}
+
+func TestNewConfigResolveDnResponse(t *testing.T) {
+	data := []byte(`<configResolveDn dn="org-root/org-a" cookie="chipsahoy!" response="yes"><outConfig><orgOrg dn="org-root/org-a" name="a" descr="tenant a"/></outConfig></configResolveDn>`)
+	res, err := NewConfigResolveDnResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.OutConfig.Objects) != 1 {
+		t.Fatalf("1 object expected; got %d", len(res.OutConfig.Objects))
+	}
+
+	if mo := res.OutConfig.Objects[0]; mo.XMLName.Local != "orgOrg" {
+		t.Errorf("%s expected; got %s", "orgOrg", mo.XMLName.Local)
+	}
+}